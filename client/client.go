@@ -1,22 +1,33 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Doom-z/RepClient/client/model"
 	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/usage"
 )
 
 type Client struct {
-	apiURL   *url.URL
+	hosts    *hostPool
+	failover Failover
 	pageSize int
 	client   *http.Client
 	apiKey   string
+	keys     *KeyPool
+	usage    *usage.Meter
+	limiter  *rateLimiter
+	window   TimeWindow
+	observer func(latency time.Duration, err error)
 }
 
 type Option func(*Client)
@@ -39,6 +50,36 @@ func WithHTTPClient(httpClient *http.Client) Option {
 	}
 }
 
+// WithRateLimit limits the client to rps requests per second with bursts of
+// up to burst requests. The limit is shared by every goroutine using the
+// client. A rps of 0 or less disables the limit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Client) {
+		if rps <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newRateLimiter(rps, burst)
+	}
+}
+
+// WithUsage counts every request, page and record with meter, per
+// endpoint and api key, and refuses requests over its daily limit.
+func WithUsage(meter *usage.Meter) Option {
+	return func(c *Client) {
+		c.usage = meter
+	}
+}
+
+// WithObserver calls fn after every request sent to the API with its
+// latency and error, e.g. to adapt the concurrency of the caller. fn is
+// called from every goroutine using the client.
+func WithObserver(fn func(latency time.Duration, err error)) Option {
+	return func(c *Client) {
+		c.observer = fn
+	}
+}
+
 // NewClient creates and configures a new Client instance.
 // rawURL is the base URL of the API server, e.g., "https://api.example.com".
 func NewClient(rawURL string, opts ...Option) (*Client, error) {
@@ -48,7 +89,6 @@ func NewClient(rawURL string, opts ...Option) (*Client, error) {
 	}
 
 	c := &Client{
-		apiURL:   parsed,
 		pageSize: 100,
 		client:   http.DefaultClient,
 		apiKey:   "",
@@ -58,6 +98,10 @@ func NewClient(rawURL string, opts ...Option) (*Client, error) {
 		opt(c)
 	}
 
+	c.hosts, err = newHostPool(parsed, c.failover)
+	if err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
@@ -88,86 +132,38 @@ func NewClient(rawURL string, opts ...Option) (*Client, error) {
 //   - Both channels will be closed when the operation completes or encounters an error.
 //   - Errors such as HTTP failures or JSON decoding issues are sent through the error channel.
 func (c *Client) FetchRecordsStream(param, value string) (<-chan model.Record, <-chan error) {
-	recordsCh := make(chan model.Record, 100)
-	errCh := make(chan error, 1)
-
-	go func() {
-		defer close(recordsCh)
-		defer close(errCh)
-
-		pageToken := ""
-
-		for {
-			reqURL := c.buildURL("/api/dns/paging", param, value, pageToken)
-
-			req, err := http.NewRequest("GET", reqURL.String(), nil)
-			if err != nil {
-				errCh <- fmt.Errorf("request creation error: %w", err)
-				return
-			}
-			req.Header.Set("Authorization", "Bearer "+c.apiKey)
-			resp, err := c.client.Do(req)
-			if err != nil {
-				errCh <- fmt.Errorf("request error: %w", err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				errCh <- fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
-				return
-			}
-
-			var result model.RecordsResponse
-			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-				errCh <- fmt.Errorf("decode error: %w", err)
-				return
-			}
-
-			for _, record := range result.Data {
-				recordsCh <- record
-			}
-
-			if !result.Pagination.HasMore {
-				break
-			}
-			pageToken = result.Pagination.NextPageToken
-		}
-	}()
+	return c.FetchRecordsStreamContext(context.Background(), param, value)
+}
 
-	return recordsCh, errCh
+// FetchRecordsStreamContext is FetchRecordsStream with a context. Cancelling
+// ctx stops the stream: pending requests are aborted and both channels are
+// closed, so callers can stop reading early without leaking the goroutine.
+func (c *Client) FetchRecordsStreamContext(ctx context.Context, param, value string) (<-chan model.Record, <-chan error) {
+	pages, errCh := c.FetchRecordPages(ctx, param, value, "")
+	return records(ctx, pages, errCh)
 }
 
 // FetchRecords limited fetches DNS records that match a specific query parameter and value.
 func (c *Client) FetchRecords(param, value string) ([]model.Record, error) {
 	query := url.Values{}
 	query.Set(param, value)
-	reqURL := c.apiURL.ResolveReference(&url.URL{
+	c.window.setQuery(query)
+	ref := &url.URL{
 		Path:     "/api/dns",
 		RawQuery: query.Encode(),
-	})
-	req, err := http.NewRequest("GET", reqURL.String(), nil)
-	if err != nil {
-		return nil, fmt.Errorf("request creation error: %w", err)
-	}
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("request error: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
-	}
-
 	var result []model.Record
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("decode error: %w", err)
+	if _, err := c.getJSON(context.Background(), ref, "", &result); err != nil {
+		return nil, err
+	}
+	records := result[:0]
+	for _, record := range result {
+		if c.window.Contains(record.Timestamp) {
+			record.NormalizeDomain()
+			records = append(records, record)
+		}
 	}
-	return result, nil
+	return records, nil
 }
 
 // FetchDNSRecords retrieves DNS records of a given type (e.g., "a", "aaaa") for the specified IP address.
@@ -200,65 +196,163 @@ func (c *Client) FetchRecords(param, value string) ([]model.Record, error) {
 //   - If an error occurs, the error channel will receive it and then close.
 //   - Make sure type T matches the expected structure of the API response's "data" field.
 func FetchDNSRecords[T any](c *Client, recordType string, ip string) (<-chan T, <-chan error) {
-	recordsCh := make(chan T, 100)
-	errCh := make(chan error, 1)
-
-	go func() {
-		defer close(recordsCh)
-		defer close(errCh)
-
-		pageToken := ""
-		for {
-			param := "ipv4"
-			if recordType == "aaaa" {
-				param = "ipv6"
-			}
-			reqURL := c.buildURL(fmt.Sprintf("/api/dns/%s", recordType), param, ip, pageToken)
-			req, err := http.NewRequest("GET", reqURL.String(), nil)
-			if err != nil {
-				errCh <- fmt.Errorf("request creation error: %w", err)
-				return
-			}
-			req.Header.Set("Authorization", "Bearer "+c.apiKey)
-			resp, err := c.client.Do(req)
-
-			if err != nil {
-				errCh <- fmt.Errorf("request error: %w", err)
-				return
-			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode != http.StatusOK {
-				body, _ := io.ReadAll(resp.Body)
-				errCh <- fmt.Errorf("unexpected status %d: %s", resp.StatusCode, body)
-				return
-			}
-
-			var result struct {
-				Data       []T                      `json:"data"`
-				Pagination model.PaginationMetadata `json:"pagination"`
-			}
-
-			if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-				errCh <- fmt.Errorf("decode error: %w", err)
-				return
-			}
-
-			for _, record := range result.Data {
-				recordsCh <- record
-			}
-			if !result.Pagination.HasMore {
-				logger.Infof("masuk$")
-				break
-			}
-			pageToken = result.Pagination.NextPageToken
+	return FetchDNSRecordsContext[T](context.Background(), c, recordType, ip)
+}
+
+// FetchDNSRecordsContext is FetchDNSRecords with a context, see
+// FetchRecordsStreamContext for the cancellation behaviour.
+func FetchDNSRecordsContext[T any](ctx context.Context, c *Client, recordType string, ip string) (<-chan T, <-chan error) {
+	pages, errCh := FetchDNSRecordPages[T](ctx, c, recordType, ip, "")
+	return records(ctx, pages, errCh)
+}
+
+// getJSON sends an authenticated GET request for ref, relative to the API
+// host, and decodes the JSON body into out. It returns the host that
+// answered. Non 200 responses are returned as *StatusError. Requests failing
+// on the host move on to the next one, except for pinned, the host of the
+// previous page, unless page tokens are portable.
+func (c *Client) getJSON(ctx context.Context, ref *url.URL, pinned string, out any) (string, error) {
+	hosts := c.hosts.candidates(pinned)
+	for i, h := range hosts {
+		err := c.getWithKeys(ctx, h.base.ResolveReference(ref), out)
+		if ctx.Err() != nil || !c.hosts.report(h, err) || i == len(hosts)-1 {
+			return h.url, err
+		}
+		logger.Debugf("%s failed on %s, trying %s: %v", ref.Path, h.url, hosts[i+1].url, err)
+	}
+	return "", nil
+}
+
+// getWithKeys sends the request with the api key. With a key pool, requests
+// failing on a quota or rate limit are retried with the next key.
+func (c *Client) getWithKeys(ctx context.Context, reqURL *url.URL, out any) error {
+	if c.keys == nil {
+		_, err := c.get(ctx, reqURL, c.apiKey, out)
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		key, err := c.keys.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		sent, err := c.get(ctx, reqURL, key.Key.Key, out)
+		if !c.keys.release(key, sent, err) || attempt >= 3*c.keys.Len() {
+			return err
+		}
+	}
+}
+
+// get sends the request with apiKey and reports whether it went out, it
+// does not when the daily limit is reached or ctx ends before sending.
+func (c *Client) get(ctx context.Context, reqURL *url.URL, apiKey string, out any) (bool, error) {
+	if c.usage != nil {
+		if err := c.usage.Request(reqURL.Path, apiKey); err != nil {
+			return false, err
+		}
+	}
+	sent, err := c.send(ctx, reqURL, apiKey, out)
+	if c.usage != nil {
+		if err != nil {
+			c.usage.Failed(reqURL.Path, apiKey)
+		} else {
+			c.usage.Page(reqURL.Path, apiKey, recordCount(out))
 		}
-	}()
+	}
+	return sent, err
+}
 
-	return recordsCh, errCh
+// recordCount returns the records decoded into out, a slice or a paging
+// response with a Data slice.
+func recordCount(out any) int {
+	v := reflect.Indirect(reflect.ValueOf(out))
+	if v.Kind() == reflect.Struct {
+		v = v.FieldByName("Data")
+	}
+	if v.Kind() != reflect.Slice {
+		return 0
+	}
+	return v.Len()
 }
 
-// buildURL constructs the full URL with query parameters for fetching DNS records.
+// send sends the request and reports whether it went out.
+func (c *Client) send(ctx context.Context, reqURL *url.URL, apiKey string, out any) (sent bool, err error) {
+	if c.limiter != nil {
+		c.limiter.Wait()
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if c.observer != nil {
+		start := time.Now()
+		defer func() { c.observer(time.Since(start), err) }()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil)
+	if err != nil {
+		return false, fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return true, &StatusError{Code: resp.StatusCode, Body: string(body), RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return true, fmt.Errorf("decode error: %w", err)
+	}
+	return true, nil
+}
+
+// StatusError is a non 200 response of the API.
+type StatusError struct {
+	Code int
+	Body string
+	// RetryAfter is the Retry-After header of the response, 0 without one
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.Code, e.Body)
+}
+
+// RateLimited reports a 429 Too Many Requests response.
+func (e *StatusError) RateLimited() bool {
+	return e.Code == http.StatusTooManyRequests
+}
+
+// QuotaExceeded reports a response saying the quota of the api key is used
+// up: 402 Payment Required, or a 403 or 429 mentioning the quota.
+func (e *StatusError) QuotaExceeded() bool {
+	switch e.Code {
+	case http.StatusPaymentRequired:
+		return true
+	case http.StatusForbidden, http.StatusTooManyRequests:
+		return strings.Contains(strings.ToLower(e.Body), "quota")
+	}
+	return false
+}
+
+// retryAfter parses a Retry-After header in seconds or as an http date.
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// buildURL constructs the URL, relative to the API host, with query parameters for fetching DNS records.
 // - param: the query key (e.g., "ip", "domain_id")
 // - value: the corresponding value to filter by
 // - pageToken: the token used to fetch the next page of results
@@ -266,12 +360,13 @@ func (c *Client) buildURL(pathApi, param, value, pageToken string) *url.URL {
 	query := url.Values{}
 	query.Set(param, value)
 	query.Set("page_size", strconv.Itoa(c.pageSize))
+	c.window.setQuery(query)
 	if pageToken != "" {
 		query.Set("page_token", pageToken)
 	}
 
-	return c.apiURL.ResolveReference(&url.URL{
+	return &url.URL{
 		Path:     pathApi,
 		RawQuery: query.Encode(),
-	})
+	}
 }
@@ -0,0 +1,183 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Host is a base URL of the API, hosts with a lower Priority are preferred.
+type Host struct {
+	URL      string
+	Priority int
+}
+
+// Failover configures the fallback hosts of a client. The base URL given
+// to NewClient is a host with priority 0, first among equal priorities.
+type Failover struct {
+	Hosts []Host
+	// MaxFailures are the consecutive failures marking a host down, default 3
+	MaxFailures int
+	// CoolDown is how long a down host is only tried as a last resort,
+	// default 1 minute
+	CoolDown time.Duration
+	// PortableTokens lets pagination continue on another host, otherwise a
+	// page_token is only sent to the host that issued it
+	PortableTokens bool
+}
+
+// HostStats are the requests of an API host.
+type HostStats struct {
+	URL      string `json:"url"`
+	Priority int    `json:"priority"`
+	Requests int    `json:"requests"`
+	// Pages are the successful responses
+	Pages    int  `json:"pages"`
+	Failures int  `json:"failures"`
+	Down     bool `json:"down,omitempty"`
+}
+
+// WithFailover sends requests to the hosts of f when the base URL fails.
+// On connection errors and 5xx responses a request moves on to the next
+// host by priority, hosts failing repeatedly rest for the cool down.
+func WithFailover(f Failover) Option {
+	return func(c *Client) {
+		c.failover = f
+	}
+}
+
+// hostPool tracks the health of the hosts of a client, it is safe for
+// concurrent use.
+type hostPool struct {
+	mu       sync.Mutex
+	hosts    []*hostState
+	failover Failover
+}
+
+type hostState struct {
+	url       string
+	base      *url.URL
+	stats     HostStats
+	failures  int // consecutive
+	downUntil time.Time
+}
+
+func newHostPool(primary *url.URL, f Failover) (*hostPool, error) {
+	if f.MaxFailures < 1 {
+		f.MaxFailures = 3
+	}
+	if f.CoolDown <= 0 {
+		f.CoolDown = time.Minute
+	}
+	p := &hostPool{failover: f}
+	p.add(primary, 0)
+	for _, h := range f.Hosts {
+		base, err := url.Parse(h.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid API URL %s: %w", h.URL, err)
+		}
+		p.add(base, h.Priority)
+	}
+	sort.SliceStable(p.hosts, func(i, j int) bool { return p.hosts[i].stats.Priority < p.hosts[j].stats.Priority })
+	return p, nil
+}
+
+func (p *hostPool) add(base *url.URL, priority int) {
+	u := base.String()
+	p.hosts = append(p.hosts, &hostState{url: u, base: base, stats: HostStats{URL: u, Priority: priority}})
+}
+
+// candidates returns the hosts to try in order: healthy hosts by priority,
+// then down hosts by the end of their cool down. pinned is the host that
+// issued the page token of the request, the only candidate unless tokens
+// are portable.
+func (p *hostPool) candidates(pinned string) []*hostState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pinned != "" && !p.failover.PortableTokens {
+		for _, h := range p.hosts {
+			if h.url == pinned {
+				return []*hostState{h}
+			}
+		}
+	}
+
+	now := time.Now()
+	var healthy, down []*hostState
+	for _, h := range p.hosts {
+		if now.Before(h.downUntil) {
+			down = append(down, h)
+		} else {
+			healthy = append(healthy, h)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool { return down[i].downUntil.Before(down[j].downUntil) })
+	return append(healthy, down...)
+}
+
+// report records the outcome of a request to h and reports whether the
+// request should move on to the next host.
+func (p *hostPool) report(h *hostState, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h.stats.Requests++
+
+	if !hostFailure(err) {
+		if err == nil {
+			h.stats.Pages++
+		}
+		if h.stats.Down && len(p.hosts) > 1 {
+			logger.Infof("API host %s is back up", h.url)
+		}
+		h.failures = 0
+		h.downUntil = time.Time{}
+		h.stats.Down = false
+		return false
+	}
+
+	h.stats.Failures++
+	h.failures++
+	if h.failures >= p.failover.MaxFailures {
+		h.downUntil = time.Now().Add(p.failover.CoolDown)
+		if !h.stats.Down && len(p.hosts) > 1 {
+			logger.Warnf("API host %s is down after %d failures, cooling down for %s: %v", h.url, h.failures, p.failover.CoolDown, err)
+		}
+		h.stats.Down = true
+	}
+	return true
+}
+
+// hostFailure reports errors of the host rather than of the request:
+// connection errors and 5xx responses.
+func hostFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.Code >= 500
+	}
+	var request *url.Error
+	return errors.As(err, &request)
+}
+
+func (p *hostPool) stats() []HostStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]HostStats, len(p.hosts))
+	for i, h := range p.hosts {
+		stats[i] = h.stats
+	}
+	return stats
+}
+
+// HostStats returns the requests of every API host, by priority.
+func (c *Client) HostStats() []HostStats {
+	return c.hosts.stats()
+}
@@ -0,0 +1,97 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hostServer serves two pages, or 503 while down is set.
+func hostServer(down *atomic.Bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		result := model.RecordsResponse{Data: []model.Record{{IP: "1.1.1.1", DomainID: "example.com"}}}
+		if r.URL.Query().Get("page_token") == "" {
+			result.Pagination = model.PaginationMetadata{HasMore: true, NextPageToken: "next"}
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func collect(t *testing.T, c *Client) ([]model.Record, error) {
+	t.Helper()
+	recordsCh, errCh := c.FetchRecordsStream("ip", "1.1.1.1")
+	var records []model.Record
+	for record := range recordsCh {
+		records = append(records, record)
+	}
+	return records, <-errCh
+}
+
+func TestFailoverMovesToNextHost(t *testing.T) {
+	var primaryDown, backupDown atomic.Bool
+	primary, backup := hostServer(&primaryDown), hostServer(&backupDown)
+	defer primary.Close()
+	defer backup.Close()
+	primaryDown.Store(true)
+
+	c, err := NewClient(primary.URL, WithFailover(Failover{Hosts: []Host{{URL: backup.URL, Priority: 1}}, MaxFailures: 1}))
+	require.NoError(t, err)
+
+	records, err := collect(t, c)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	stats := c.HostStats()
+	assert.Equal(t, primary.URL, stats[0].URL)
+	assert.True(t, stats[0].Down)
+	assert.Equal(t, 1, stats[0].Failures)
+	assert.Equal(t, 2, stats[1].Pages)
+
+	// the primary is down and cooling down, so the backup comes first
+	primaryDown.Store(false)
+	_, err = collect(t, c)
+	require.NoError(t, err)
+	assert.Equal(t, 1, c.HostStats()[0].Requests)
+}
+
+func TestFailoverPinsPageTokens(t *testing.T) {
+	var primaryDown, backupDown atomic.Bool
+	primary, backup := hostServer(&primaryDown), hostServer(&backupDown)
+	defer primary.Close()
+	defer backup.Close()
+
+	for _, portable := range []bool{false, true} {
+		c, err := NewClient(primary.URL, WithFailover(Failover{Hosts: []Host{{URL: backup.URL}}, PortableTokens: portable}))
+		require.NoError(t, err)
+
+		ref := c.buildURL("/api/dns/paging", "ip", "1.1.1.1", "")
+		var page model.RecordsResponse
+		host, err := c.getJSON(t.Context(), ref, "", &page)
+		require.NoError(t, err)
+		assert.Equal(t, primary.URL, host)
+
+		primaryDown.Store(true)
+		ref = c.buildURL("/api/dns/paging", "ip", "1.1.1.1", page.Pagination.NextPageToken)
+		host, err = c.getJSON(t.Context(), ref, host, &page)
+		if portable {
+			require.NoError(t, err)
+			assert.Equal(t, backup.URL, host)
+		} else {
+			var status *StatusError
+			require.ErrorAs(t, err, &status)
+			assert.Equal(t, http.StatusServiceUnavailable, status.Code)
+			assert.Zero(t, c.HostStats()[1].Requests)
+		}
+		primaryDown.Store(false)
+	}
+}
@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// ErrKeysExhausted is returned when every key of a KeyPool is out of quota
+// or over its daily budget.
+var ErrKeysExhausted = errors.New("every api key is out of quota or over its daily budget")
+
+// defaultCoolDown is how long a rate limited key rests without a Retry-After.
+const defaultCoolDown = 30 * time.Second
+
+// Key is an API key of a KeyPool.
+type Key struct {
+	Key string
+	// Weight is the share of requests relative to the other keys, 0 counts as 1
+	Weight int
+	// DailyBudget is the max requests per UTC day, 0 for no limit
+	DailyBudget int
+}
+
+// KeyStats are the requests of a pool key, in the order the keys were given.
+type KeyStats struct {
+	// Calls are the requests of this run
+	Calls       int  `json:"calls"`
+	RateLimited int  `json:"rate_limited,omitempty"`
+	Exhausted   bool `json:"exhausted,omitempty"`
+	// Today are the requests of the current UTC day, including other runs
+	Today       int `json:"today"`
+	DailyBudget int `json:"daily_budget,omitempty"`
+}
+
+// KeyPool spreads requests over several API keys by weight, skipping keys
+// over their daily budget. A key answering with a quota error is dropped
+// for the day and a rate limited key rests, the request is retried with
+// the next key. The pool is safe for concurrent use.
+type KeyPool struct {
+	mu   sync.Mutex
+	keys []*poolKey
+	path string
+	day  string
+}
+
+type poolKey struct {
+	Key
+	index     int
+	id        string
+	current   int // smooth weighted round robin state
+	pending   int // requests acquired and not released yet
+	stats     KeyStats
+	coolUntil time.Time
+	unsaved   map[string]int // requests per day not written to the usage file yet
+}
+
+// keyUsage is the usage file: the requests of every key per UTC day, keys
+// are identified by a hash so the file holds no secrets.
+type keyUsage struct {
+	Keys map[string]map[string]int `json:"keys"`
+}
+
+// NewKeyPool creates a pool of keys. usagePath is a json file with the
+// daily requests of every key, so budgets hold across runs. It is read
+// here and updated by Save, an empty path keeps the usage in memory.
+func NewKeyPool(keys []Key, usagePath string) (*KeyPool, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("key pool without keys")
+	}
+	usage, err := loadKeyUsage(usagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &KeyPool{path: usagePath, day: utcDay(time.Now())}
+	for i, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("api key %d is empty", i+1)
+		}
+		if key.Weight < 1 {
+			key.Weight = 1
+		}
+		k := &poolKey{Key: key, index: i, id: keyID(key.Key), unsaved: map[string]int{}}
+		k.stats.DailyBudget = key.DailyBudget
+		k.stats.Today = usage.Keys[k.id][p.day]
+		p.keys = append(p.keys, k)
+	}
+	return p, nil
+}
+
+// WithKeyPool sends the requests of the client with the keys of pool
+// instead of a single api key.
+func WithKeyPool(pool *KeyPool) Option {
+	return func(c *Client) {
+		c.keys = pool
+	}
+}
+
+// acquire returns the key for the next request, which must be given back
+// with release. The request holds a place in the daily budget of the key
+// until then. When every usable key is resting it waits for the first one.
+func (p *KeyPool) acquire(ctx context.Context) (*poolKey, error) {
+	for {
+		p.mu.Lock()
+		now := time.Now()
+		p.rollDay(now)
+
+		var best *poolKey
+		var wait time.Duration
+		total := 0
+		for _, k := range p.keys {
+			if k.stats.Exhausted || (k.DailyBudget > 0 && k.stats.Today+k.pending >= k.DailyBudget) {
+				continue
+			}
+			if rest := k.coolUntil.Sub(now); rest > 0 {
+				if wait == 0 || rest < wait {
+					wait = rest
+				}
+				continue
+			}
+			k.current += k.Weight
+			total += k.Weight
+			if best == nil || k.current > best.current {
+				best = k
+			}
+		}
+		if best != nil {
+			best.current -= total
+			best.pending++
+			p.mu.Unlock()
+			return best, nil
+		}
+		p.mu.Unlock()
+
+		if wait == 0 {
+			return nil, ErrKeysExhausted
+		}
+		logger.Debugf("every api key is rate limited, waiting %s", wait.Round(time.Second))
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// release gives back k, acquired for a request, and reports whether the
+// request should be retried with another key. Only a request that was
+// sent counts toward the calls and the daily budget of the key, not one
+// refused by the daily limit of the run or cancelled before it went out.
+func (p *KeyPool) release(k *poolKey, sent bool, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	k.pending--
+	if sent {
+		k.stats.Calls++
+		k.stats.Today++
+		k.unsaved[p.day]++
+	}
+
+	var status *StatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	switch {
+	case status.QuotaExceeded():
+		k.stats.Exhausted = true
+		logger.Warnf("api key %d is out of quota, rotating to the next key", k.index+1)
+	case status.RateLimited():
+		k.stats.RateLimited++
+		rest := status.RetryAfter
+		if rest <= 0 {
+			rest = defaultCoolDown
+		}
+		k.coolUntil = time.Now().Add(rest)
+		logger.Warnf("api key %d is rate limited for %s, rotating to the next key", k.index+1, rest)
+	default:
+		return false
+	}
+	return true
+}
+
+// rollDay restarts the daily counters when the UTC day changed, from the
+// usage file, which holds the requests other runs made on the new day.
+func (p *KeyPool) rollDay(now time.Time) {
+	day := utcDay(now)
+	if day == p.day {
+		return
+	}
+	p.day = day
+	usage, err := loadKeyUsage(p.path)
+	if err != nil {
+		logger.Warnf("key usage of %s not reloaded: %v", day, err)
+	}
+	for _, k := range p.keys {
+		k.stats.Today = usage.Keys[k.id][day] + k.unsaved[day]
+		k.stats.Exhausted = false
+	}
+}
+
+// Len returns the number of keys.
+func (p *KeyPool) Len() int {
+	return len(p.keys)
+}
+
+// Stats returns the usage of every key, in the order they were given.
+func (p *KeyPool) Stats() []KeyStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]KeyStats, len(p.keys))
+	for i, k := range p.keys {
+		stats[i] = k.stats
+	}
+	return stats
+}
+
+// Save adds the requests since the last save to the usage file. The file
+// is read again under a lock first, so concurrent runs sharing it do not
+// lose each other's requests.
+func (p *KeyPool) Save() error {
+	if p.path == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var usage keyUsage
+	err := fileutil.UpdateJSON(p.path, &usage, func() error {
+		if usage.Keys == nil {
+			usage.Keys = map[string]map[string]int{}
+		}
+		for _, k := range p.keys {
+			for day, n := range k.unsaved {
+				if usage.Keys[k.id] == nil {
+					usage.Keys[k.id] = map[string]int{}
+				}
+				usage.Keys[k.id][day] += n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range p.keys {
+		clear(k.unsaved)
+	}
+	return nil
+}
+
+func loadKeyUsage(path string) (keyUsage, error) {
+	usage := keyUsage{Keys: map[string]map[string]int{}}
+	if path == "" {
+		return usage, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	}
+	if err != nil {
+		return usage, err
+	}
+	if err := json.Unmarshal(data, &usage); err != nil {
+		return usage, fmt.Errorf("invalid key usage file %s: %w", path, err)
+	}
+	if usage.Keys == nil {
+		usage.Keys = map[string]map[string]int{}
+	}
+	return usage, nil
+}
+
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func utcDay(t time.Time) string {
+	return t.UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keyServer answers with the status of the bearer key, 200 by default.
+func keyServer(statuses map[string]int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Authorization")[len("Bearer "):]
+		if status, ok := statuses[key]; ok {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(status)
+			if status == http.StatusPaymentRequired {
+				w.Write([]byte("daily quota exceeded"))
+			}
+			return
+		}
+		json.NewEncoder(w).Encode([]model.Record{{IP: "1.1.1.1", DomainID: "example.com"}})
+	}))
+}
+
+// take acquires a key of pool for a request that is sent.
+func take(t *testing.T, pool *KeyPool) {
+	t.Helper()
+	k, err := pool.acquire(context.Background())
+	require.NoError(t, err)
+	pool.release(k, true, nil)
+}
+
+func TestKeyPoolRotatesOnQuota(t *testing.T) {
+	srv := keyServer(map[string]int{"first": http.StatusPaymentRequired, "second": http.StatusTooManyRequests})
+	defer srv.Close()
+
+	pool, err := NewKeyPool([]Key{{Key: "first", Weight: 3}, {Key: "second", Weight: 2}, {Key: "third"}}, "")
+	require.NoError(t, err)
+	c, err := NewClient(srv.URL, WithKeyPool(pool))
+	require.NoError(t, err)
+
+	for range 2 {
+		records, err := c.FetchRecords("ip", "1.1.1.1")
+		require.NoError(t, err)
+		assert.Len(t, records, 1)
+	}
+
+	stats := pool.Stats()
+	assert.True(t, stats[0].Exhausted)
+	assert.Equal(t, 1, stats[0].Calls)
+	assert.Equal(t, 1, stats[1].RateLimited)
+	assert.Equal(t, 2, stats[2].Calls)
+}
+
+func TestKeyPoolWeights(t *testing.T) {
+	pool, err := NewKeyPool([]Key{{Key: "a", Weight: 3}, {Key: "b"}}, "")
+	require.NoError(t, err)
+
+	for range 8 {
+		take(t, pool)
+	}
+	stats := pool.Stats()
+	assert.Equal(t, 6, stats[0].Calls)
+	assert.Equal(t, 2, stats[1].Calls)
+}
+
+func TestKeyPoolBudgetAcrossRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "usage.json")
+	keys := []Key{{Key: "a", DailyBudget: 3}}
+
+	pool, err := NewKeyPool(keys, path)
+	require.NoError(t, err)
+	for range 2 {
+		take(t, pool)
+	}
+	require.NoError(t, pool.Save())
+
+	pool, err = NewKeyPool(keys, path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pool.Stats()[0].Today)
+	take(t, pool)
+	_, err = pool.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrKeysExhausted)
+}
+
+func TestKeyPoolCountsSentRequests(t *testing.T) {
+	srv := keyServer(nil)
+	defer srv.Close()
+
+	pool, err := NewKeyPool([]Key{{Key: "a", DailyBudget: 2}}, "")
+	require.NoError(t, err)
+	meter := usage.NewMeter(usage.Limits{MaxRequestsPerDay: 1}, nil)
+	c, err := NewClient(srv.URL, WithKeyPool(pool), WithUsage(meter))
+	require.NoError(t, err)
+
+	_, err = c.FetchRecords("ip", "1.1.1.1")
+	require.NoError(t, err)
+	_, err = c.FetchRecords("ip", "1.1.1.1")
+	require.ErrorIs(t, err, usage.ErrLimitReached)
+	assert.Equal(t, 1, pool.Stats()[0].Today, "a request refused by the meter is not sent")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c, err = NewClient(srv.URL, WithKeyPool(pool))
+	require.NoError(t, err)
+	records, errs := c.FetchRecordsStreamContext(ctx, "ip", "1.1.1.1")
+	for range records {
+	}
+	require.NoError(t, <-errs)
+	assert.Equal(t, 1, pool.Stats()[0].Calls, "a cancelled request is not sent")
+
+	take(t, pool)
+	_, err = pool.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrKeysExhausted, "the budget holds the sent requests only")
+}
+
+func TestKeyPoolRollDayReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	keys := []Key{{Key: "a", DailyBudget: 3}}
+
+	pool, err := NewKeyPool(keys, path)
+	require.NoError(t, err)
+	other, err := NewKeyPool(keys, path)
+	require.NoError(t, err)
+	take(t, other)
+	take(t, other)
+	require.NoError(t, other.Save())
+
+	// the pool started on the previous day, before the other run saved
+	pool.day = "2000-01-01"
+	take(t, pool)
+	assert.Equal(t, 3, pool.Stats()[0].Today, "the requests of the other run count")
+	_, err = pool.acquire(context.Background())
+	assert.ErrorIs(t, err, ErrKeysExhausted)
+}
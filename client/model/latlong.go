@@ -0,0 +1,44 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LatLong is a parsed "lat,long" location of a full mode record.
+type LatLong struct {
+	Lat  float64 `json:"lat"`
+	Long float64 `json:"long"`
+}
+
+// ParseLatLong parses a "lat,long" string such as "37.770,-122.41".
+// Empty strings, non numeric values and out of range coordinates are errors.
+func ParseLatLong(s string) (LatLong, error) {
+	latStr, longStr, ok := strings.Cut(strings.TrimSpace(s), ",")
+	if !ok {
+		return LatLong{}, fmt.Errorf("invalid latlong %q: expected lat,long", s)
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	if err != nil {
+		return LatLong{}, fmt.Errorf("invalid latitude in %q: %w", s, err)
+	}
+	long, err := strconv.ParseFloat(strings.TrimSpace(longStr), 64)
+	if err != nil {
+		return LatLong{}, fmt.Errorf("invalid longitude in %q: %w", s, err)
+	}
+	if lat < -90 || lat > 90 || long < -180 || long > 180 {
+		return LatLong{}, fmt.Errorf("latlong %q out of range", s)
+	}
+	return LatLong{Lat: lat, Long: long}, nil
+}
+
+// Location parses the LatLong field of the record.
+func (r ARecord) Location() (LatLong, error) {
+	return ParseLatLong(r.LatLong)
+}
+
+// Location parses the LatLong field of the record.
+func (r AAAARecord) Location() (LatLong, error) {
+	return ParseLatLong(r.LatLong)
+}
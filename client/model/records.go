@@ -1,10 +1,47 @@
 package model
 
+import "github.com/Doom-z/RepClient/pkg/psl"
+
+// Timestamped is implemented by every record type.
+type Timestamped interface {
+	GetTimestamp() int64
+}
+
+// DomainNormalizer is implemented by records that carry the public suffix
+// split of their domain.
+type DomainNormalizer interface {
+	NormalizeDomain()
+}
+
 type Record struct {
 	IP         string `json:"ip"`
 	DomainID   string `json:"domain_id"`
 	RecordType string `json:"record_type"`
 	Timestamp  int64  `json:"timestamp"`
+
+	// ASN, ASNName, Country and City are only set by offline enrichment
+	ASN     int    `json:"asn,omitempty"`
+	ASNName string `json:"asn_name,omitempty"`
+	Country string `json:"country,omitempty"`
+	City    string `json:"city,omitempty"`
+
+	RegistrableDomain string `json:"registrable_domain,omitempty"`
+	PublicSuffix      string `json:"public_suffix,omitempty"`
+	Subdomain         string `json:"subdomain,omitempty"`
+}
+
+func (r Record) GetDomainID() string {
+	return r.DomainID
+}
+
+func (r Record) GetTimestamp() int64 {
+	return r.Timestamp
+}
+
+// NormalizeDomain sets the public suffix split of DomainID.
+func (r *Record) NormalizeDomain() {
+	parts := psl.Split(r.DomainID)
+	r.RegistrableDomain, r.PublicSuffix, r.Subdomain = parts.RegistrableDomain, parts.PublicSuffix, parts.Subdomain
 }
 
 type ARecord struct {
@@ -16,12 +53,26 @@ type ARecord struct {
 	City      string `db:"city" json:"city" cql:"city"`
 	LatLong   string `db:"latlong" json:"latlong" cql:"latlong"`
 	Timestamp int64  `db:"timestamp" json:"timestamp" cql:"timestamp"`
+
+	RegistrableDomain string `db:"-" json:"registrable_domain,omitempty" cql:"-"`
+	PublicSuffix      string `db:"-" json:"public_suffix,omitempty" cql:"-"`
+	Subdomain         string `db:"-" json:"subdomain,omitempty" cql:"-"`
 }
 
 func (r ARecord) GetDomainID() string {
 	return r.DomainID
 }
 
+func (r ARecord) GetTimestamp() int64 {
+	return r.Timestamp
+}
+
+// NormalizeDomain sets the public suffix split of DomainID.
+func (r *ARecord) NormalizeDomain() {
+	parts := psl.Split(r.DomainID)
+	r.RegistrableDomain, r.PublicSuffix, r.Subdomain = parts.RegistrableDomain, parts.PublicSuffix, parts.Subdomain
+}
+
 type AAAARecord struct {
 	DomainID  string `db:"domain_id" json:"domain_id" cql:"domain_id"`
 	IP        string `db:"ip" json:"ip" cql:"ip"`
@@ -31,8 +82,22 @@ type AAAARecord struct {
 	City      string `db:"city" json:"city" cql:"city"`
 	LatLong   string `db:"latlong" json:"latlong" cql:"latlong"`
 	Timestamp int64  `db:"timestamp" json:"timestamp" cql:"timestamp"`
+
+	RegistrableDomain string `db:"-" json:"registrable_domain,omitempty" cql:"-"`
+	PublicSuffix      string `db:"-" json:"public_suffix,omitempty" cql:"-"`
+	Subdomain         string `db:"-" json:"subdomain,omitempty" cql:"-"`
 }
 
 func (r AAAARecord) GetDomainID() string {
 	return r.DomainID
 }
+
+func (r AAAARecord) GetTimestamp() int64 {
+	return r.Timestamp
+}
+
+// NormalizeDomain sets the public suffix split of DomainID.
+func (r *AAAARecord) NormalizeDomain() {
+	parts := psl.Split(r.DomainID)
+	r.RegistrableDomain, r.PublicSuffix, r.Subdomain = parts.RegistrableDomain, parts.PublicSuffix, parts.Subdomain
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Page is a page of a paginated endpoint, with the records outside the
+// time window left out.
+type Page[T any] struct {
+	Records []T
+	// Token is the page_token the page was fetched with, empty for the first page
+	Token string
+	// Next is the page_token of the following page, empty on the last page
+	Next string
+	// Number counts the pages of the stream from 1
+	Number int
+	// Host is the API host that served the page
+	Host string
+}
+
+// FetchRecordPages streams the pages of the records that match param and
+// value, starting at pageToken, or at the first page when it is empty.
+// Cancelling ctx stops the stream and closes both channels. Resuming a
+// stream stopped early at the Next token of the last page read, or the
+// Token of a page only partly read, continues where it left off.
+func (c *Client) FetchRecordPages(ctx context.Context, param, value, pageToken string) (<-chan Page[model.Record], <-chan error) {
+	return fetchPages[model.Record](ctx, c, "/api/dns/paging", param, value, pageToken)
+}
+
+// FetchDNSRecordPages is FetchRecordPages for the full mode records of
+// recordType ("a" or "aaaa") of ip, see FetchDNSRecords.
+func FetchDNSRecordPages[T any](ctx context.Context, c *Client, recordType, ip, pageToken string) (<-chan Page[T], <-chan error) {
+	param := "ipv4"
+	if recordType == "aaaa" {
+		param = "ipv6"
+	}
+	return fetchPages[T](ctx, c, fmt.Sprintf("/api/dns/%s", recordType), param, ip, pageToken)
+}
+
+func fetchPages[T any](ctx context.Context, c *Client, pathApi, param, value, pageToken string) (<-chan Page[T], <-chan error) {
+	pagesCh := make(chan Page[T])
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(pagesCh)
+		defer close(errCh)
+
+		host := ""
+		scan := windowScan{window: c.window}
+		for number := 1; ; number++ {
+			ref := c.buildURL(pathApi, param, value, pageToken)
+
+			var result struct {
+				Data       []T                      `json:"data"`
+				Pagination model.PaginationMetadata `json:"pagination"`
+			}
+			var err error
+			if host, err = c.getJSON(ctx, ref, host, &result); err != nil {
+				if ctx.Err() == nil {
+					errCh <- err
+				}
+				return
+			}
+			logger.Debugf("%s %s: page %d from %s", param, value, number, host)
+
+			page := Page[T]{Records: result.Data[:0], Token: pageToken, Number: number, Host: host}
+			for _, record := range result.Data {
+				if ts, ok := any(record).(model.Timestamped); ok {
+					scan.observe(ts.GetTimestamp())
+					if !c.window.Contains(ts.GetTimestamp()) {
+						continue
+					}
+				}
+				if n, ok := any(&record).(model.DomainNormalizer); ok {
+					n.NormalizeDomain()
+				}
+				page.Records = append(page.Records, record)
+			}
+			if result.Pagination.HasMore {
+				if scan.passed() {
+					logger.Debugf("%s %s: remaining pages are outside the time window", param, value)
+				} else {
+					page.Next = result.Pagination.NextPageToken
+				}
+			}
+
+			select {
+			case pagesCh <- page:
+			case <-ctx.Done():
+				return
+			}
+			if page.Next == "" {
+				return
+			}
+			pageToken = page.Next
+		}
+	}()
+
+	return pagesCh, errCh
+}
+
+// records streams the records of pages, see FetchRecordsStreamContext.
+func records[T any](ctx context.Context, pages <-chan Page[T], pageErrs <-chan error) (<-chan T, <-chan error) {
+	recordsCh := make(chan T, 100)
+	errCh := make(chan error, 1)
+
+	go func() {
+		defer close(recordsCh)
+		defer close(errCh)
+
+		for page := range pages {
+			for _, record := range page.Records {
+				select {
+				case recordsCh <- record:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+		if err := <-pageErrs; err != nil {
+			errCh <- err
+		}
+	}()
+
+	return recordsCh, errCh
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenServer serves 3 pages of 2 a records, page n has the token "n".
+func tokenServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+		var result struct {
+			Data       []model.ARecord          `json:"data"`
+			Pagination model.PaginationMetadata `json:"pagination"`
+		}
+		result.Pagination = model.PaginationMetadata{HasMore: page < 2, NextPageToken: strconv.Itoa(page + 1)}
+		for i := 1; i <= 2; i++ {
+			result.Data = append(result.Data, model.ARecord{IP: r.URL.Query().Get("ipv4"), DomainID: "example.com", Timestamp: int64(page*2 + i)})
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestFetchDNSRecordPages(t *testing.T) {
+	srv := tokenServer()
+	defer srv.Close()
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+
+	var pages []Page[model.ARecord]
+	pagesCh, errCh := FetchDNSRecordPages[model.ARecord](t.Context(), c, "a", "1.1.1.1", "")
+	for page := range pagesCh {
+		pages = append(pages, page)
+	}
+	require.NoError(t, <-errCh)
+	require.Len(t, pages, 3)
+	assert.Equal(t, "", pages[0].Token)
+	assert.Equal(t, "1", pages[0].Next)
+	assert.Equal(t, "2", pages[2].Token)
+	assert.Equal(t, "", pages[2].Next, "the last page has no next token")
+	assert.Equal(t, 3, pages[2].Number)
+	assert.Equal(t, int64(6), pages[2].Records[1].Timestamp)
+}
+
+func TestFetchDNSRecordPagesResume(t *testing.T) {
+	srv := tokenServer()
+	defer srv.Close()
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+
+	var timestamps []int64
+	pagesCh, errCh := FetchDNSRecordPages[model.ARecord](t.Context(), c, "a", "1.1.1.1", "1")
+	for page := range pagesCh {
+		for _, record := range page.Records {
+			timestamps = append(timestamps, record.Timestamp)
+		}
+	}
+	require.NoError(t, <-errCh)
+	assert.Equal(t, []int64{3, 4, 5, 6}, timestamps)
+}
+
+func TestFetchRecordPagesCancel(t *testing.T) {
+	srv := tokenServer()
+	defer srv.Close()
+	c, err := NewClient(srv.URL)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	pagesCh, errCh := c.FetchRecordPages(ctx, "ip", "1.1.1.1", "")
+	<-pagesCh
+	cancel()
+	for range pagesCh {
+	}
+	assert.NoError(t, <-errCh, "a cancelled stream ends without an error")
+}
@@ -0,0 +1,62 @@
+package client
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPClientCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	plain, err := NewHTTPClient(TransportConfig{})
+	require.NoError(t, err)
+	_, err = plain.Get(srv.URL)
+	assert.Error(t, err, "the test CA is not trusted by default")
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0644))
+	trusted, err := NewHTTPClient(TransportConfig{CABundle: bundle, MinTLSVersion: "1.3"})
+	require.NoError(t, err)
+	resp, err := trusted.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	c, err := NewHTTPClient(TransportConfig{Proxy: proxy.URL})
+	require.NoError(t, err)
+	resp, err := c.Get("http://api.example.com/api/dns?ip=1.1.1.1")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "http://api.example.com/api/dns?ip=1.1.1.1", proxied)
+
+	_, err = NewHTTPClient(TransportConfig{Proxy: "socks5://127.0.0.1:1080"})
+	assert.NoError(t, err)
+}
+
+func TestNewHTTPClientErrors(t *testing.T) {
+	for _, cfg := range []TransportConfig{
+		{Proxy: "ftp://proxy:21"},
+		{MinTLSVersion: "1.4"},
+		{CertFile: "client.pem"},
+		{CABundle: "missing.pem"},
+	} {
+		_, err := NewHTTPClient(cfg)
+		assert.Error(t, err, "%+v", cfg)
+	}
+}
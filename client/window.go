@@ -0,0 +1,95 @@
+package client
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TimeWindow limits records to Since <= Timestamp <= Until. A zero bound
+// is open.
+type TimeWindow struct {
+	Since time.Time
+	Until time.Time
+}
+
+func (w TimeWindow) IsZero() bool {
+	return w.Since.IsZero() && w.Until.IsZero()
+}
+
+// Contains reports whether the unix timestamp ts is inside the window.
+func (w TimeWindow) Contains(ts int64) bool {
+	if !w.Since.IsZero() && ts < w.Since.Unix() {
+		return false
+	}
+	if !w.Until.IsZero() && ts > w.Until.Unix() {
+		return false
+	}
+	return true
+}
+
+// setQuery adds the bounds to query as "since" and "until" unix seconds.
+func (w TimeWindow) setQuery(query url.Values) {
+	if !w.Since.IsZero() {
+		query.Set("since", strconv.FormatInt(w.Since.Unix(), 10))
+	}
+	if !w.Until.IsZero() {
+		query.Set("until", strconv.FormatInt(w.Until.Unix(), 10))
+	}
+}
+
+// WithTimeWindow only returns the records inside w. The bounds are sent to
+// the API and enforced on every record, as the API may ignore them.
+func WithTimeWindow(w TimeWindow) Option {
+	return func(c *Client) {
+		c.window = w
+	}
+}
+
+// windowScan follows the timestamps of a paginated stream to tell when
+// the pages left can not hold records inside the window. This is the case
+// once every timestamp so far was ordered and the stream moved past the
+// window in that order.
+type windowScan struct {
+	window    TimeWindow
+	last      int64
+	seen      bool
+	direction int // 1 ascending, -1 descending, 0 unknown
+	unordered bool
+}
+
+func (s *windowScan) observe(ts int64) {
+	if !s.seen {
+		s.seen, s.last = true, ts
+		return
+	}
+	switch {
+	case ts > s.last:
+		if s.direction < 0 {
+			s.unordered = true
+		}
+		s.direction = 1
+	case ts < s.last:
+		if s.direction > 0 {
+			s.unordered = true
+		}
+		s.direction = -1
+	}
+	s.last = ts
+}
+
+// passed reports whether the stream is ordered and already past the window.
+func (s *windowScan) passed() bool {
+	if s.unordered || !s.seen {
+		return false
+	}
+	w := s.window
+	switch s.direction {
+	case 1:
+		return !w.Until.IsZero() && s.last > w.Until.Unix()
+	case -1:
+		return !w.Since.IsZero() && s.last < w.Since.Unix()
+	default:
+		return false
+	}
+}
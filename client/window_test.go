@@ -0,0 +1,62 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// pagingServer serves 3 pages of 2 records with timestamps 1 to 6.
+func pagingServer(t *testing.T, requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		assert.Equal(t, "3", r.URL.Query().Get("until"))
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+		resp := model.RecordsResponse{Pagination: model.PaginationMetadata{HasMore: page < 2, NextPageToken: strconv.Itoa(page + 1)}}
+		for i := 1; i <= 2; i++ {
+			ts := int64(page*2 + i)
+			resp.Data = append(resp.Data, model.Record{IP: "1.1.1.1", DomainID: "example.com", Timestamp: ts})
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestStreamTimeWindow(t *testing.T) {
+	var requests int32
+	srv := pagingServer(t, &requests)
+	defer srv.Close()
+
+	c, err := NewClient(srv.URL, WithTimeWindow(TimeWindow{Since: time.Unix(2, 0), Until: time.Unix(3, 0)}))
+	assert.NoError(t, err)
+
+	var timestamps []int64
+	recordsCh, errCh := c.FetchRecordsStream("ip", "1.1.1.1")
+	for record := range recordsCh {
+		timestamps = append(timestamps, record.Timestamp)
+	}
+	assert.NoError(t, <-errCh)
+	assert.Equal(t, []int64{2, 3}, timestamps)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests), "the last page is past the window")
+}
+
+func TestWindowScanUnordered(t *testing.T) {
+	s := windowScan{window: TimeWindow{Until: time.Unix(4, 0)}}
+	for _, ts := range []int64{1, 5, 3, 6} {
+		s.observe(ts)
+	}
+	assert.False(t, s.passed(), "unordered streams are read to the end")
+
+	s = windowScan{window: TimeWindow{Since: time.Unix(4, 0)}}
+	for _, ts := range []int64{9, 5, 3} {
+		s.observe(ts)
+	}
+	assert.True(t, s.passed())
+}
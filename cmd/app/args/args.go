@@ -1,20 +1,202 @@
 package args
 
+import "time"
+
 type Args struct {
-	Trial    bool   `arg:"--trial" help:"trial mode" default:"false"`
-	Ipv4     string `arg:"-i,--ipv4" help:"ipv4 address to query"`
-	Ipv6     string `arg:"--ipv6" help:"ipv6 address to query"`
-	Ns       string `arg:"-s,--ns" help:"ns to query"`
-	Cname    string `arg:"-n,--cname" help:"cname to query"`
-	Txt      string `arg:"-t,--txt" help:"txt to query"`
-	Mx       string `arg:"-x,--mx" help:"mx to query"`
-	ListFile string `arg:"-l,--list-file" help:"Path to file containing list of DNS entries (ipv4, ipv6, ns, cname, txt, mx); type will be auto-detected"`
-	ModeFull bool   `arg:"-f,--full" help:"full mode, fetch all column such as ASN, ASN Name, City, Country, etc" default:"false"`
-
-	MaxTotalOutputIp int    `arg:"-m,--max" help:"max total output per ip" default:"100"`
-	PageSize         int    `arg:"-p,--page-size" help:"page size" default:"100"`
-	Output           bool   `arg:"-o,--output" help:"output to file" default:"false"`
-	Threads          int    `arg:"-t,--threads" help:"number of threads" default:"1"`
-	Verbose          bool   `arg:"-v,--verbose" help:"verbose output" default:"false"`
-	Config           string `arg:"-c,--config" help:"config file" default:"config.toml"`
+	Query     *QueryCmd      `arg:"subcommand:query" help:"fetch the records of one target with a single request, as the trial API does"`
+	Stream    *StreamCmd     `arg:"subcommand:stream" help:"page through the records of one target"`
+	Bulk      *BulkCmd       `arg:"subcommand:bulk" help:"query every target of a list file"`
+	Profile   *ProfileCmd    `arg:"subcommand:profile" help:"full mode A or AAAA records of an IP, with ASN, geo and location columns"`
+	Pivot     *PivotCmd      `arg:"subcommand:pivot" help:"crawl from seed targets to related IPs and domains and build a graph"`
+	Watch     *WatchCmd      `arg:"subcommand:watch" help:"re-query targets on a schedule and notify about changes"`
+	Diff      *DiffCmd       `arg:"subcommand:diff" help:"compare two result sets and report added, removed and changed records"`
+	Stats     *StatsCmd      `arg:"subcommand:stats" help:"count records of output files by ASN, country, city, domain and day"`
+	Cidr      *CidrCmd       `arg:"subcommand:cidr" help:"collapse the IPs of output files into CIDR prefixes"`
+	Convert   *ConvertCmd    `arg:"subcommand:convert" help:"convert output files to another format"`
+	Usage     *UsageCmd      `arg:"subcommand:usage" help:"show the API requests, pages and records of past runs per day or month"`
+	ConfigCmd *ConfigCmd     `arg:"subcommand:config" help:"show, create or validate the config file, list its profiles"`
+	Complete  *CompletionCmd `arg:"subcommand:completion" help:"print the shell completion script"`
+
+	Config      string `arg:"-c,--config" help:"config file" default:"config.toml"`
+	ProfileName string `arg:"--profile,env:REPCLIENT_PROFILE" help:"apply the [profiles.<name>] table of the config file"`
+	Verbose     bool   `arg:"-v,--verbose" help:"verbose output" default:"false"`
+	NoProgress  bool   `arg:"--no-progress" help:"disable the progress display" default:"false"`
+}
+
+// TargetFlags select the single target of query and stream, exactly one
+// of them is required.
+type TargetFlags struct {
+	Ipv4  string `arg:"-i,--ipv4" help:"ipv4 address to query"`
+	Ns    string `arg:"-s,--ns" help:"ns to query"`
+	Cname string `arg:"-n,--cname" help:"cname to query"`
+	Txt   string `arg:"--txt" help:"txt to query"`
+	Mx    string `arg:"-x,--mx" help:"mx to query"`
+}
+
+type PagingFlags struct {
+	MaxTotalOutputIp int `arg:"-m,--max" help:"max records per target, 0 for no limit" default:"100"`
+	PageSize         int `arg:"-p,--page-size" help:"page size" default:"100"`
+}
+
+// AdaptiveFlags let the number of threads follow the API instead of
+// staying at --threads.
+type AdaptiveFlags struct {
+	Adaptive   bool `arg:"--adaptive" help:"grow the number of threads while requests are fast and shrink it on slow responses, errors and 429s, starting at --threads" default:"false"`
+	MinThreads int  `arg:"--min-threads" help:"lower bound of --adaptive" default:"1"`
+	MaxThreads int  `arg:"--max-threads" help:"upper bound of --adaptive" default:"16"`
+}
+
+// BudgetFlags bound the time and pages spent on every target and on the
+// whole run. Targets stopped by a budget are marked as truncated in the run
+// summary, with the page_token to resume them.
+type BudgetFlags struct {
+	MaxPages      int           `arg:"--max-pages" help:"max pages per target, 0 for no limit" default:"0"`
+	TargetTimeout time.Duration `arg:"--target-timeout" help:"max time spent on a target, e.g. 10m, 0 for no limit" default:"0"`
+	Deadline      time.Duration `arg:"--deadline" help:"stop the run after this long, e.g. 2h; targets not done by then are truncated, 0 for no limit" default:"0"`
+}
+
+type WindowFlags struct {
+	Since string `arg:"--since" help:"only records seen at or after this time: 2024-05-01, RFC 3339, unix seconds or a duration ago such as 30d"`
+	Until string `arg:"--until" help:"only records seen at or before this time, same formats as --since"`
+}
+
+// OutputFlags select where the records of a run are written.
+type OutputFlags struct {
+	Output     bool   `arg:"-o,--output" help:"output to file" default:"false"`
+	Summary    string `arg:"--summary" help:"path of the run summary json, overrides output.summary"`
+	Template   string `arg:"--template" help:"write output files with a go text/template per record: a preset (domain, domain-ip, hosts, csv, tsv), @file or inline. overrides output.template and sets the template format"`
+	Timestamp  string `arg:"--timestamp" help:"timestamp representation in output files and logs: epoch, rfc3339, local, both. overrides output.timestamp"`
+	GroupBy    string `arg:"--group-by" help:"write output files into one directory per value of a record field, e.g. registrable_domain, country, asn"`
+	Aggregate  string `arg:"--aggregate" help:"print counts by ASN, country, city, domain and day after the run: table, json, csv. written to aggregate.<format> in output.dir while logs go to stdout"`
+	CidrFormat string `arg:"--cidr" help:"print the IPs of the run collapsed into CIDR prefixes after the run: list, csv, json. written to cidr.<format> in output.dir while logs go to stdout"`
+	CidrByASN  bool   `arg:"--cidr-by-asn" help:"collapse the IPs of every ASN on their own for --cidr" default:"false"`
+	Graph      string `arg:"--graph" help:"write a relationship graph of every record to this file (.json, .dot, .graphml, .gexf), overrides output.graph"`
+}
+
+type QueryCmd struct {
+	TargetFlags
+	WindowFlags
+	OutputFlags
+}
+
+type StreamCmd struct {
+	TargetFlags
+	PageToken string `arg:"--page-token" help:"resume a truncated target at the page_token of its run summary"`
+	PagingFlags
+	BudgetFlags
+	WindowFlags
+	OutputFlags
+}
+
+type BulkCmd struct {
+	ListFile string `arg:"positional,required" help:"file with one target per line (ipv4, ipv6, ns, cname, txt, mx); the type is auto-detected"`
+	Threads  int    `arg:"-t,--threads" help:"number of threads" default:"1"`
+	Trial    bool   `arg:"--trial" help:"a single request per target as in query, the only bulk mode of the trial API" default:"false"`
+	AdaptiveFlags
+	PagingFlags
+	BudgetFlags
+	WindowFlags
+	OutputFlags
+}
+
+type ProfileCmd struct {
+	IP        string `arg:"positional,required" help:"ipv4 or ipv6 address"`
+	PageSize  int    `arg:"-p,--page-size" help:"page size" default:"100"`
+	Max       int    `arg:"-m,--max" help:"max records, 0 for no limit" default:"0"`
+	PageToken string `arg:"--page-token" help:"resume a truncated profile at the page_token of its run summary"`
+	BudgetFlags
+	WindowFlags
+	OutputFlags
+}
+
+type ConvertCmd struct {
+	Paths     []string `arg:"positional,required" help:"output files or directories (ndjson, json, csv, txt)"`
+	Out       string   `arg:"--out,required" help:"converted file, its extension selects the format: .ndjson, .json, .csv, .txt"`
+	Timestamp string   `arg:"--timestamp" help:"rewrite timestamps: epoch, rfc3339, local, both"`
+}
+
+type UsageCmd struct {
+	Period string `arg:"--period" help:"total per day or month" default:"day"`
+	By     string `arg:"--by" help:"also group by endpoint or key"`
+	Last   int    `arg:"--last" help:"only the last periods, 0 for all" default:"0"`
+	Format string `arg:"--format" help:"output format: table, json, csv" default:"table"`
+}
+
+type ConfigCmd struct {
+	Show     *ConfigShowCmd     `arg:"subcommand:show" help:"print the config file merged over the defaults, with secrets redacted"`
+	Init     *ConfigInitCmd     `arg:"subcommand:init" help:"write the default config to a file"`
+	Profiles *ConfigProfilesCmd `arg:"subcommand:profiles" help:"list the profiles of the config file"`
+	Validate *ConfigValidateCmd `arg:"subcommand:validate" help:"check the config for unknown keys, invalid values and unwritable directories"`
+}
+
+type ConfigShowCmd struct {
+	Effective bool `arg:"--effective" help:"apply the REPCLIENT_* environment variables and api_key_file or api_key_cmd, as a run does" default:"false"`
+}
+
+type ConfigInitCmd struct {
+	Path  string `arg:"positional" help:"config file to write (default: the --config path)"`
+	Force bool   `arg:"--force" help:"overwrite an existing file" default:"false"`
+}
+
+type ConfigProfilesCmd struct{}
+
+type ConfigValidateCmd struct{}
+
+type CompletionCmd struct {
+	Shell string `arg:"positional,required" help:"bash, zsh or fish"`
+}
+
+type DiffCmd struct {
+	Old    string   `arg:"positional,required" help:"old output file or directory (ndjson, json, csv, txt)"`
+	New    string   `arg:"positional,required" help:"new output file or directory (ndjson, json, csv, txt)"`
+	Keys   []string `arg:"-k,--keys,separate" help:"fields used to match records (default: domain_id, ip)"`
+	Fields []string `arg:"--fields,separate" help:"fields compared on matched records (default: asn, country, city)"`
+	Format string   `arg:"--format" help:"output format: summary, ndjson" default:"summary"`
+	Out    string   `arg:"--out" help:"write the diff to this file instead of stdout"`
+}
+
+type WatchCmd struct {
+	ListFile string        `arg:"-l,--list-file,required" help:"file with one target per line, the type is auto-detected"`
+	Threads  int           `arg:"-t,--threads" help:"number of threads" default:"1"`
+	Full     bool          `arg:"-f,--full" help:"watch the full mode A records of ip targets" default:"false"`
+	Every    time.Duration `arg:"--every" help:"interval between cycles" default:"6h"`
+	Jitter   time.Duration `arg:"--jitter" help:"random delay up to this duration added to every cycle" default:"5m"`
+	StateDir string        `arg:"--state-dir" help:"directory of the per target snapshots, overrides watch.state_dir"`
+	Notify   []string      `arg:"--notify,separate" help:"notification sink: stdout, file:PATH or webhook:URL, overrides watch.notify"`
+	Keys     []string      `arg:"-k,--keys,separate" help:"fields used to match records (default: domain_id, ip)"`
+	Fields   []string      `arg:"--fields,separate" help:"fields compared on matched records (default: asn, country, city)"`
+	Once     bool          `arg:"--once" help:"run a single cycle and exit, e.g. when started from cron" default:"false"`
+	Summary  string        `arg:"--summary" help:"path of the run summary json of the last cycle, overrides output.summary"`
+	PageSize int           `arg:"-p,--page-size" help:"page size" default:"100"`
+	Max      int           `arg:"-m,--max" help:"max records per target, 0 for no limit; a capped snapshot reports the records pushed out of it as removed" default:"0"`
+	BudgetFlags
+}
+
+type PivotCmd struct {
+	Seeds    []string `arg:"positional" help:"seed IPs or domains, added to the targets of --list-file"`
+	ListFile string   `arg:"-l,--list-file" help:"file with one seed per line"`
+	Threads  int      `arg:"-t,--threads" help:"number of threads" default:"1"`
+	Depth    int      `arg:"--depth" help:"number of hops to follow from the seeds" default:"2"`
+	MaxNodes int      `arg:"--max-nodes" help:"max number of nodes to expand, 0 for no limit" default:"100"`
+	Types    []string `arg:"--types,separate" help:"query parameters used to expand domains (default: ip, ns, mx, cname)"`
+	Out      string   `arg:"--out" help:"graph output file (default: pivot.<format> inside the output dir)"`
+	Format   string   `arg:"--format" help:"graph format: json, dot, graphml, gexf (default: by --out extension, else json)"`
+	PagingFlags
+	BudgetFlags
+	OutputFlags
+}
+
+type StatsCmd struct {
+	Paths  []string `arg:"positional,required" help:"output files or directories (ndjson, json, csv, txt)"`
+	By     []string `arg:"--by,separate" help:"dimensions to count: asn, asn_name, country, city, domain, day (default: all)"`
+	Top    int      `arg:"--top" help:"max entries per dimension, 0 for all" default:"10"`
+	Format string   `arg:"--format" help:"output format: table, json, csv" default:"table"`
+	Out    string   `arg:"--out" help:"write the report to this file instead of stdout"`
+}
+
+type CidrCmd struct {
+	Paths  []string `arg:"positional,required" help:"output files or directories (ndjson, json, csv)"`
+	ByASN  bool     `arg:"--by-asn" help:"collapse the IPs of every ASN on their own" default:"false"`
+	Format string   `arg:"--format" help:"output format: list, csv, json" default:"list"`
+	Out    string   `arg:"--out" help:"write the prefixes to this file instead of stdout"`
 }
@@ -0,0 +1,221 @@
+package args
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// Options are the settings of a run against the API, built from the flags
+// of the subcommand that starts it.
+type Options struct {
+	Trial bool
+	TargetFlags
+	Ipv6     string
+	ListFile string
+	ModeFull bool
+	// PageToken resumes the single target of stream and profile
+	PageToken string
+	PagingFlags
+	BudgetFlags
+	WindowFlags
+	OutputFlags
+	Threads int
+	AdaptiveFlags
+	Verbose    bool
+	NoProgress bool
+}
+
+// DefaultPaging is used by subcommands without paging flags.
+var DefaultPaging = PagingFlags{MaxTotalOutputIp: 100, PageSize: 100}
+
+// Options returns the run options of the selected subcommand, false when
+// the subcommand does not query the API.
+func (a Args) Options() (Options, bool) {
+	o := Options{
+		PagingFlags: DefaultPaging,
+		Threads:     1,
+		Verbose:     a.Verbose,
+		NoProgress:  a.NoProgress,
+	}
+	switch {
+	case a.Query != nil:
+		o.Trial = true
+		o.TargetFlags, o.WindowFlags, o.OutputFlags = a.Query.TargetFlags, a.Query.WindowFlags, a.Query.OutputFlags
+	case a.Stream != nil:
+		o.TargetFlags, o.PagingFlags, o.WindowFlags, o.OutputFlags = a.Stream.TargetFlags, a.Stream.PagingFlags, a.Stream.WindowFlags, a.Stream.OutputFlags
+		o.PageToken, o.BudgetFlags = a.Stream.PageToken, a.Stream.BudgetFlags
+	case a.Bulk != nil:
+		o.ListFile, o.Threads, o.Trial, o.AdaptiveFlags = a.Bulk.ListFile, a.Bulk.Threads, a.Bulk.Trial, a.Bulk.AdaptiveFlags
+		o.PagingFlags, o.BudgetFlags, o.WindowFlags, o.OutputFlags = a.Bulk.PagingFlags, a.Bulk.BudgetFlags, a.Bulk.WindowFlags, a.Bulk.OutputFlags
+	case a.Profile != nil:
+		o.ModeFull = true
+		if addr, err := netip.ParseAddr(a.Profile.IP); err == nil && addr.Is6() && !addr.Is4In6() {
+			o.Ipv6 = a.Profile.IP
+		} else {
+			o.Ipv4 = a.Profile.IP
+		}
+		o.PageSize, o.MaxTotalOutputIp, o.PageToken = a.Profile.PageSize, a.Profile.Max, a.Profile.PageToken
+		o.BudgetFlags, o.WindowFlags, o.OutputFlags = a.Profile.BudgetFlags, a.Profile.WindowFlags, a.Profile.OutputFlags
+	case a.Pivot != nil:
+		o.ListFile, o.Threads = a.Pivot.ListFile, a.Pivot.Threads
+		o.PagingFlags, o.BudgetFlags, o.OutputFlags = a.Pivot.PagingFlags, a.Pivot.BudgetFlags, a.Pivot.OutputFlags
+	case a.Watch != nil:
+		o.ListFile, o.Threads, o.ModeFull = a.Watch.ListFile, a.Watch.Threads, a.Watch.Full
+		o.PageSize, o.MaxTotalOutputIp, o.Summary = a.Watch.PageSize, a.Watch.Max, a.Watch.Summary
+		o.BudgetFlags = a.Watch.BudgetFlags
+	default:
+		return Options{}, false
+	}
+	return o, true
+}
+
+// Validate reports conflicting or missing flags of the selected subcommand.
+func (a Args) Validate() error {
+	switch {
+	case a.Query != nil:
+		return a.Query.TargetFlags.Validate()
+	case a.Stream != nil:
+		if err := a.Stream.TargetFlags.Validate(); err != nil {
+			return err
+		}
+		if err := a.Stream.BudgetFlags.Validate(); err != nil {
+			return err
+		}
+		return a.Stream.PagingFlags.Validate()
+	case a.Bulk != nil:
+		if a.Bulk.Threads < 1 {
+			return errors.New("--threads must be at least 1")
+		}
+		if err := a.Bulk.AdaptiveFlags.Validate(a.Bulk.Threads); err != nil {
+			return err
+		}
+		if err := a.Bulk.BudgetFlags.Validate(); err != nil {
+			return err
+		}
+		return a.Bulk.PagingFlags.Validate()
+	case a.Profile != nil:
+		if _, err := netip.ParseAddr(a.Profile.IP); err != nil {
+			return fmt.Errorf("%q is not an ipv4 or ipv6 address", a.Profile.IP)
+		}
+		if a.Profile.PageSize < 1 {
+			return errors.New("--page-size must be at least 1")
+		}
+		if a.Profile.Max < 0 {
+			return errors.New("--max must not be negative")
+		}
+		return a.Profile.BudgetFlags.Validate()
+	case a.Pivot != nil:
+		if len(a.Pivot.Seeds) == 0 && a.Pivot.ListFile == "" {
+			return errors.New("seed targets or --list-file are required")
+		}
+		if a.Pivot.Threads < 1 {
+			return errors.New("--threads must be at least 1")
+		}
+		if err := a.Pivot.BudgetFlags.Validate(); err != nil {
+			return err
+		}
+		return a.Pivot.PagingFlags.Validate()
+	case a.Watch != nil:
+		if a.Watch.Threads < 1 {
+			return errors.New("--threads must be at least 1")
+		}
+		if !a.Watch.Once && a.Watch.Every <= 0 {
+			return errors.New("--every must be greater than 0")
+		}
+		if a.Watch.Jitter < 0 {
+			return errors.New("--jitter must not be negative")
+		}
+		if err := a.Watch.BudgetFlags.Validate(); err != nil {
+			return err
+		}
+		return PagingFlags{MaxTotalOutputIp: a.Watch.Max, PageSize: a.Watch.PageSize}.Validate()
+	case a.ConfigCmd != nil:
+		if a.ConfigCmd.Show == nil && a.ConfigCmd.Init == nil && a.ConfigCmd.Profiles == nil && a.ConfigCmd.Validate == nil {
+			return errors.New("one of show, init, profiles, validate is required")
+		}
+	}
+	return nil
+}
+
+// Validate requires exactly one target flag.
+func (t TargetFlags) Validate() error {
+	var set []string
+	for _, target := range t.targets() {
+		if target.value != "" {
+			set = append(set, target.flag)
+		}
+	}
+	switch len(set) {
+	case 0:
+		return errors.New("one of --ipv4, --ns, --cname, --txt, --mx is required")
+	case 1:
+		return nil
+	default:
+		return fmt.Errorf("only one target can be queried at a time, got %s", strings.Join(set, ", "))
+	}
+}
+
+// Target returns the query parameter and value of the set target flag.
+func (t TargetFlags) Target() (param, value string) {
+	for _, target := range t.targets() {
+		if target.value != "" {
+			return target.param, target.value
+		}
+	}
+	return "", ""
+}
+
+type target struct {
+	flag, param, value string
+}
+
+func (t TargetFlags) targets() []target {
+	return []target{
+		{"--ipv4", "ip", t.Ipv4},
+		{"--ns", "ns", t.Ns},
+		{"--cname", "cname", t.Cname},
+		{"--txt", "txt", t.Txt},
+		{"--mx", "mx", t.Mx},
+	}
+}
+
+// Validate requires --min-threads <= threads <= --max-threads with --adaptive.
+func (f AdaptiveFlags) Validate(threads int) error {
+	if !f.Adaptive {
+		return nil
+	}
+	if f.MinThreads < 1 {
+		return errors.New("--min-threads must be at least 1")
+	}
+	if f.MaxThreads < f.MinThreads {
+		return errors.New("--max-threads must not be below --min-threads")
+	}
+	if threads < f.MinThreads || threads > f.MaxThreads {
+		return fmt.Errorf("--threads must be between --min-threads %d and --max-threads %d with --adaptive", f.MinThreads, f.MaxThreads)
+	}
+	return nil
+}
+
+func (p PagingFlags) Validate() error {
+	if p.MaxTotalOutputIp < 0 {
+		return errors.New("--max must not be negative")
+	}
+	if p.PageSize < 1 {
+		return errors.New("--page-size must be at least 1")
+	}
+	return nil
+}
+
+func (b BudgetFlags) Validate() error {
+	switch {
+	case b.MaxPages < 0:
+		return errors.New("--max-pages must not be negative")
+	case b.TargetTimeout < 0:
+		return errors.New("--target-timeout must not be negative")
+	case b.Deadline < 0:
+		return errors.New("--deadline must not be negative")
+	}
+	return nil
+}
@@ -1,30 +1,69 @@
 package cfg
 
-import "github.com/sirupsen/logrus"
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/utils"
+	"github.com/sirupsen/logrus"
+)
 
 const Name = "repclient"
 
 type Conf struct {
-	App    App    `toml:"app"`
-	Api    Api    `toml:"api"`
-	Output Output `toml:"output"`
-	Log    Log    `toml:"log"`
+	App    App    `toml:"app" json:"app"`
+	Api    Api    `toml:"api" json:"api"`
+	Output Output `toml:"output" json:"output"`
+	Log    Log    `toml:"log" json:"log"`
+	Watch  Watch  `toml:"watch" json:"watch"`
+	Enrich Enrich `toml:"enrich" json:"enrich"`
+	Usage  Usage  `toml:"usage" json:"usage"`
+
+	// Profile is the name of the applied [profiles.<name>] table
+	Profile string `toml:"-" json:"profile,omitempty"`
 }
 
 type Output struct {
-	Format string `toml:"format"`
-	Dir    string `toml:"dir"`
+	Format  string `toml:"format" json:"format"`
+	Dir     string `toml:"dir" json:"dir"`
+	Summary string `toml:"summary" json:"summary"`
+	// Timestamp is how record timestamps are written: epoch, rfc3339, local or both
+	Timestamp string `toml:"timestamp" json:"timestamp"`
+	// Template is the line template of the template format: a preset name,
+	// @ followed by a template file or an inline go text/template
+	Template string `toml:"template" json:"template"`
+	// Graph is the relationship graph file, its extension selects the format
+	Graph   string  `toml:"graph" json:"graph"`
+	Webhook Webhook `toml:"webhook" json:"webhook"`
+}
+
+type Webhook struct {
+	URL    string `toml:"url" json:"url"`
+	Secret string `toml:"secret" json:"secret"`
+	// Template is a go text/template rendering the json body of a batch
+	Template      string            `toml:"template" json:"template"`
+	Headers       map[string]string `toml:"headers" json:"headers"`
+	BatchSize     int               `toml:"batch_size" json:"batch_size"`
+	FlushInterval time.Duration     `toml:"flush_interval" json:"flush_interval"`
+	MaxRetries    int               `toml:"max_retries" json:"max_retries"`
+	Backoff       time.Duration     `toml:"backoff" json:"backoff"`
+	Timeout       time.Duration     `toml:"timeout" json:"timeout"`
+	DeadLetter    string            `toml:"dead_letter" json:"dead_letter"`
 }
 
 type App struct {
-	Name string `toml:"name"`
+	Name        string `toml:"name" json:"name"`
+	Description string `toml:"description" json:"description"`
 }
 
 type File struct {
-	Format  LogFormat `toml:"format"`
-	Path    string    `toml:"path"`
-	MaxSize int       `toml:"max_size"`
-	MaxAge  int       `toml:"max_age"`
+	Format  LogFormat `toml:"format" json:"format"`
+	Path    string    `toml:"path" json:"path"`
+	MaxSize int       `toml:"max_size" json:"max_size"`
+	MaxAge  int       `toml:"max_age" json:"max_age"`
 }
 
 type LogFormat string
@@ -43,19 +82,104 @@ const (
 )
 
 type Stdout struct {
-	Format LogFormat `toml:"format"`
-	Output LogOutput `toml:"output"`
+	Format LogFormat `toml:"format" json:"format"`
+	Output LogOutput `toml:"output" json:"output"`
 }
 
 type Api struct {
-	Host   string `toml:"host"`
-	Apikey string `toml:"api_key"`
+	Host string `toml:"host" json:"host"`
+	// Hosts are fallback base URLs, requests failing on host move on to them
+	// by priority, lower first. host has priority 0
+	Hosts []ApiHost `toml:"hosts" json:"hosts"`
+	// HostMaxFailures are the consecutive failures marking a host down and
+	// HostCoolDown is how long it is only tried as a last resort
+	HostMaxFailures int           `toml:"host_max_failures" json:"host_max_failures"`
+	HostCoolDown    time.Duration `toml:"host_cool_down" json:"host_cool_down"`
+	// PortablePageTokens lets pagination continue on another host, by default
+	// the next page is only requested from the host that issued the page_token
+	PortablePageTokens bool `toml:"portable_page_tokens" json:"portable_page_tokens"`
+
+	Apikey string `toml:"api_key" json:"api_key"`
+	// ApikeyFile and ApikeyCmd read the api key from a file or the stdout of
+	// a credential helper instead, so it does not sit in the config
+	ApikeyFile string `toml:"api_key_file" json:"api_key_file"`
+	ApikeyCmd  string `toml:"api_key_cmd" json:"api_key_cmd"`
+	// RateLimit is the max requests per second shared by all workers, 0 disables it
+	RateLimit float64 `toml:"rate_limit" json:"rate_limit"`
+	Burst     int     `toml:"burst" json:"burst"`
+	// Keys is a pool of api keys used instead of api_key, requests are spread
+	// by weight and move on to the next key on quota or rate limit errors
+	Keys []ApiKey `toml:"keys" json:"keys"`
+	// KeyUsage is the file keeping the daily requests of every pool key
+	KeyUsage  string    `toml:"key_usage" json:"key_usage"`
+	Transport Transport `toml:"transport" json:"transport"`
+}
+
+// Transport configures the http client of the API requests, zero values
+// keep the Go defaults.
+type Transport struct {
+	// Proxy is an http, https, socks5 or socks5h url, empty uses HTTPS_PROXY
+	Proxy string `toml:"proxy" json:"proxy"`
+	// CABundle is a pem file with CAs trusted next to the system ones
+	CABundle string `toml:"ca_bundle" json:"ca_bundle"`
+	// CertFile and KeyFile are the pem client certificate and key for mTLS
+	CertFile      string `toml:"cert_file" json:"cert_file"`
+	KeyFile       string `toml:"key_file" json:"key_file"`
+	MinTLSVersion string `toml:"min_tls_version" json:"min_tls_version"`
+
+	// Timeout limits a whole request, 0 for none
+	Timeout               time.Duration `toml:"timeout" json:"timeout"`
+	DialTimeout           time.Duration `toml:"dial_timeout" json:"dial_timeout"`
+	TLSHandshakeTimeout   time.Duration `toml:"tls_handshake_timeout" json:"tls_handshake_timeout"`
+	ResponseHeaderTimeout time.Duration `toml:"response_header_timeout" json:"response_header_timeout"`
+	IdleConnTimeout       time.Duration `toml:"idle_conn_timeout" json:"idle_conn_timeout"`
+
+	MaxIdleConns        int `toml:"max_idle_conns" json:"max_idle_conns"`
+	MaxIdleConnsPerHost int `toml:"max_idle_conns_per_host" json:"max_idle_conns_per_host"`
+	MaxConnsPerHost     int `toml:"max_conns_per_host" json:"max_conns_per_host"`
+}
+
+type ApiHost struct {
+	URL      string `toml:"url" json:"url"`
+	Priority int    `toml:"priority" json:"priority"`
+}
+
+type ApiKey struct {
+	Key string `toml:"key" json:"key"`
+	// Weight is the share of requests relative to the other keys, default 1
+	Weight int `toml:"weight" json:"weight"`
+	// DailyBudget is the max requests per UTC day, 0 for no limit
+	DailyBudget int `toml:"daily_budget" json:"daily_budget"`
+}
+
+type Watch struct {
+	StateDir string   `toml:"state_dir" json:"state_dir"`
+	Notify   []string `toml:"notify" json:"notify"`
+}
+
+// Enrich lists the local databases used to add ASN and geo fields to
+// lightweight records, enrichment is off when none is set.
+type Enrich struct {
+	// MMDB are MaxMind format databases, e.g. GeoLite2-City.mmdb and GeoLite2-ASN.mmdb
+	MMDB []string `toml:"mmdb" json:"mmdb"`
+	// IP2ASN are ip2asn TSV files, e.g. ip2asn-combined.tsv from iptoasn.com
+	IP2ASN []string `toml:"ip2asn" json:"ip2asn"`
+}
+
+// Usage is the local accounting of API requests, pages and records.
+type Usage struct {
+	// Ledger is the file keeping the counts per day, endpoint and api key
+	Ledger string `toml:"ledger" json:"ledger"`
+	// MaxRequestsPerDay refuses requests once reached, 0 for no limit
+	MaxRequestsPerDay int `toml:"max_requests_per_day" json:"max_requests_per_day"`
+	// SoftRequestsPerDay logs a warning once reached, 0 for no warning
+	SoftRequestsPerDay int `toml:"soft_requests_per_day" json:"soft_requests_per_day"`
 }
 
 type Log struct {
-	Level  string   `toml:"level"`
-	File   []File   `toml:"file"`
-	Stdout []Stdout `toml:"stdout"`
+	Level  string   `toml:"level" json:"level"`
+	File   []File   `toml:"file" json:"file"`
+	Stdout []Stdout `toml:"stdout" json:"stdout"`
 }
 
 func GetDefaultConf() Conf {
@@ -64,18 +188,116 @@ func GetDefaultConf() Conf {
 			Name: Name,
 		},
 		Api: Api{
-			Host:   "https://repproject.world",
-			Apikey: "@repproject",
+			Host:            "https://repproject.world",
+			HostMaxFailures: 3,
+			HostCoolDown:    time.Minute,
+			Apikey:          "@repproject",
+			Burst:           1,
+			KeyUsage:        userStateFile("key-usage.json"),
 		},
 		Output: Output{
-			Format: "ndjson",
-			Dir:    "output",
+			Format:    "ndjson",
+			Dir:       "output",
+			Summary:   "summary.json",
+			Timestamp: "epoch",
+			Webhook: Webhook{
+				BatchSize:     100,
+				FlushInterval: 10 * time.Second,
+				MaxRetries:    3,
+				Backoff:       time.Second,
+				Timeout:       30 * time.Second,
+				DeadLetter:    "webhook-dead-letter.ndjson",
+			},
 		},
 		Log: Log{
 			Level:  "info",
 			Stdout: []Stdout{{Format: LogFormatText, Output: LogOutputStdout}},
 		},
+		Watch: Watch{
+			StateDir: "state",
+			Notify:   []string{"stdout"},
+		},
+		Usage: Usage{
+			Ledger: userStateFile("usage.json"),
+		},
+	}
+}
+
+// userStateFile returns name inside the user config dir of the app, e.g.
+// ~/.config/repclient on Linux, so the usage files are shared by the runs of
+// every working directory. It is empty, which disables the file, when the
+// user config dir is unknown.
+func userStateFile(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, Name, name)
+}
+
+// Redacted returns a copy of the config that is safe to print or persist.
+func (c Conf) Redacted() Conf {
+	c.Api.Apikey = utils.RedactSecret(c.Api.Apikey)
+	if proxy, err := url.Parse(c.Api.Transport.Proxy); err == nil {
+		c.Api.Transport.Proxy = proxy.Redacted()
+	}
+	if len(c.Api.Keys) > 0 {
+		keys := make([]ApiKey, len(c.Api.Keys))
+		for i, key := range c.Api.Keys {
+			key.Key = utils.RedactSecret(key.Key)
+			keys[i] = key
+		}
+		c.Api.Keys = keys
+	}
+	c.Output.Webhook.URL = redactURL(c.Output.Webhook.URL)
+	c.Output.Webhook.Secret = utils.RedactSecret(c.Output.Webhook.Secret)
+	if len(c.Output.Webhook.Headers) > 0 {
+		headers := make(map[string]string, len(c.Output.Webhook.Headers))
+		for k, v := range c.Output.Webhook.Headers {
+			headers[k] = utils.RedactSecret(v)
+		}
+		c.Output.Webhook.Headers = headers
 	}
+	if len(c.Watch.Notify) > 0 {
+		notify := make([]string, len(c.Watch.Notify))
+		for i, spec := range c.Watch.Notify {
+			if hook, ok := strings.CutPrefix(spec, "webhook:"); ok {
+				spec = "webhook:" + redactURL(hook)
+			}
+			notify[i] = spec
+		}
+		c.Watch.Notify = notify
+	}
+	return c
+}
+
+// redactURL keeps the scheme and host of a url and masks its user info,
+// path and query, which hold the token of Slack or Discord style hooks.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return utils.RedactSecret(raw)
+	}
+	redacted := u.Scheme + "://"
+	if u.User != nil {
+		redacted += "****@"
+	}
+	redacted += u.Host
+	if strings.Trim(u.Path, "/") != "" {
+		redacted += "/****"
+	}
+	if u.RawQuery != "" {
+		redacted += "?****"
+	}
+	return redacted
+}
+
+// Trial reports whether the config uses the key of the trial API.
+func (c Conf) Trial() bool {
+	return c.Api.Apikey == "@repproject" && len(c.Api.Keys) == 0
 }
 
 // levelToLogrusLevel converts a string to a logrus.Level
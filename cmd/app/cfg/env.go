@@ -0,0 +1,139 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the environment variables overriding config keys, the
+// rest is the upper case toml path joined by "_", e.g. REPCLIENT_API_API_KEY
+// for api.api_key or REPCLIENT_OUTPUT_WEBHOOK_URL for output.webhook.url.
+const EnvPrefix = "REPCLIENT_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ApplyEnv overrides the keys of conf found by lookup, e.g. os.LookupEnv,
+// and returns the names of the variables used. Lists are comma separated,
+// maps are comma separated key=value pairs. An api key source set in the
+// environment replaces the api_key, api_key_file, api_key_cmd and keys pool
+// of the config file.
+func ApplyEnv(conf *Conf, lookup func(string) (string, bool)) ([]string, error) {
+	var used []string
+	err := walkEnv(reflect.ValueOf(conf).Elem(), strings.TrimSuffix(EnvPrefix, "_"), func(name string, v reflect.Value) error {
+		value, ok := lookup(name)
+		if !ok {
+			return nil
+		}
+		if err := setEnvValue(v, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+		used = append(used, name)
+		return nil
+	})
+	if err != nil {
+		return used, err
+	}
+	conf.Api.useKeySources(
+		slices.Contains(used, EnvPrefix+"API_API_KEY"),
+		slices.Contains(used, EnvPrefix+"API_API_KEY_FILE"),
+		slices.Contains(used, EnvPrefix+"API_API_KEY_CMD"),
+		// the pool can only be set in the config file
+		false,
+	)
+	return used, nil
+}
+
+func walkEnv(v reflect.Value, prefix string, fn func(name string, v reflect.Value) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("toml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(key)
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := walkEnv(field, name, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(name, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setEnvValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s can only be set in the config file", v.Type())
+		}
+		list := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, item := range splitList(value) {
+			list = reflect.Append(list, reflect.ValueOf(item).Convert(v.Type().Elem()))
+		}
+		v.Set(list)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%s can only be set in the config file", v.Type())
+		}
+		m := reflect.MakeMap(v.Type())
+		for _, pair := range splitList(value) {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("expected key=value, got %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(k)), reflect.ValueOf(strings.TrimSpace(val)))
+		}
+		v.Set(m)
+	default:
+		return fmt.Errorf("%s can only be set in the config file", v.Type())
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,126 @@
+package cfg
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	conf := GetDefaultConf()
+	used, err := ApplyEnv(&conf, lookup(map[string]string{
+		"REPCLIENT_API_HOST":                      "https://env.example.com",
+		"REPCLIENT_API_RATE_LIMIT":                "2.5",
+		"REPCLIENT_API_BURST":                     "3",
+		"REPCLIENT_API_PORTABLE_PAGE_TOKENS":      "true",
+		"REPCLIENT_API_TRANSPORT_TIMEOUT":         "30s",
+		"REPCLIENT_OUTPUT_WEBHOOK_HEADERS":        "X-Team=red, X-Env = prod",
+		"REPCLIENT_WATCH_NOTIFY":                  "stdout, file:changes.ndjson,",
+		"REPCLIENT_LOG_LEVEL":                     "debug",
+		"REPCLIENT_UNKNOWN_KEY":                   "ignored",
+		"REPCLIENT_OUTPUT_WEBHOOK_FLUSH_INTERVAL": "1m30s",
+	}))
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"REPCLIENT_API_HOST",
+		"REPCLIENT_API_RATE_LIMIT",
+		"REPCLIENT_API_BURST",
+		"REPCLIENT_API_PORTABLE_PAGE_TOKENS",
+		"REPCLIENT_API_TRANSPORT_TIMEOUT",
+		"REPCLIENT_OUTPUT_WEBHOOK_HEADERS",
+		"REPCLIENT_WATCH_NOTIFY",
+		"REPCLIENT_LOG_LEVEL",
+		"REPCLIENT_OUTPUT_WEBHOOK_FLUSH_INTERVAL",
+	}, used)
+	assert.Equal(t, "https://env.example.com", conf.Api.Host)
+	assert.Equal(t, 2.5, conf.Api.RateLimit)
+	assert.Equal(t, 3, conf.Api.Burst)
+	assert.True(t, conf.Api.PortablePageTokens)
+	assert.Equal(t, 30*time.Second, conf.Api.Transport.Timeout)
+	assert.Equal(t, 90*time.Second, conf.Output.Webhook.FlushInterval)
+	assert.Equal(t, map[string]string{"X-Team": "red", "X-Env": "prod"}, conf.Output.Webhook.Headers)
+	assert.Equal(t, []string{"stdout", "file:changes.ndjson"}, conf.Watch.Notify)
+	assert.Equal(t, "debug", conf.Log.Level)
+	assert.Equal(t, "ndjson", conf.Output.Format, "keys without a variable keep their value")
+	assert.Equal(t, "@repproject", conf.Api.Apikey)
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	tests := []struct {
+		name, value, err string
+	}{
+		{"REPCLIENT_API_TRANSPORT_TIMEOUT", "soon", `invalid REPCLIENT_API_TRANSPORT_TIMEOUT: time: invalid duration "soon"`},
+		{"REPCLIENT_API_BURST", "many", `invalid REPCLIENT_API_BURST: strconv.ParseInt: parsing "many": invalid syntax`},
+		{"REPCLIENT_API_RATE_LIMIT", "fast", `invalid REPCLIENT_API_RATE_LIMIT: strconv.ParseFloat: parsing "fast": invalid syntax`},
+		{"REPCLIENT_API_PORTABLE_PAGE_TOKENS", "maybe", `invalid REPCLIENT_API_PORTABLE_PAGE_TOKENS: strconv.ParseBool: parsing "maybe": invalid syntax`},
+		{"REPCLIENT_OUTPUT_WEBHOOK_HEADERS", "X-Team", `invalid REPCLIENT_OUTPUT_WEBHOOK_HEADERS: expected key=value, got "X-Team"`},
+		{"REPCLIENT_API_KEYS", "a,b", `invalid REPCLIENT_API_KEYS: []cfg.ApiKey can only be set in the config file`},
+		{"REPCLIENT_LOG_STDOUT", "stderr", `invalid REPCLIENT_LOG_STDOUT: []cfg.Stdout can only be set in the config file`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := GetDefaultConf()
+			_, err := ApplyEnv(&conf, lookup(map[string]string{tt.name: tt.value}))
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestApplyEnvKeySourceReplacesPool(t *testing.T) {
+	conf := GetDefaultConf()
+	conf.Api.Keys = []ApiKey{{Key: "pool-a"}, {Key: "pool-b"}}
+	_, err := ApplyEnv(&conf, lookup(map[string]string{"REPCLIENT_API_API_KEY": "env-key"}))
+	require.NoError(t, err)
+	assert.Equal(t, "env-key", conf.Api.Apikey)
+	assert.Empty(t, conf.Api.Keys, "the client would prefer the pool over the env key")
+
+	conf.Api.Keys = []ApiKey{{Key: "pool-a"}}
+	_, err = ApplyEnv(&conf, lookup(map[string]string{"REPCLIENT_OUTPUT_DIR": "env"}))
+	require.NoError(t, err)
+	assert.Len(t, conf.Api.Keys, 1, "other variables keep the pool")
+}
+
+func TestApplyEnvKeySource(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		key, file, cm string
+	}{
+		{
+			name: "api key replaces file and cmd",
+			env:  map[string]string{"REPCLIENT_API_API_KEY": "env-key"},
+			key:  "env-key",
+		},
+		{
+			name: "key file replaces api key and cmd",
+			env:  map[string]string{"REPCLIENT_API_API_KEY_FILE": "/run/secrets/key"},
+			file: "/run/secrets/key",
+		},
+		{
+			name: "other variables keep the sources of the file",
+			env:  map[string]string{"REPCLIENT_API_HOST": "https://env.example.com"},
+			key:  "file-key", file: "key.txt", cm: "pass show key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := GetDefaultConf()
+			conf.Api.Apikey, conf.Api.ApikeyFile, conf.Api.ApikeyCmd = "file-key", "key.txt", "pass show key"
+			_, err := ApplyEnv(&conf, lookup(tt.env))
+			require.NoError(t, err)
+			assert.Equal(t, tt.key, conf.Api.Apikey)
+			assert.Equal(t, tt.file, conf.Api.ApikeyFile)
+			assert.Equal(t, tt.cm, conf.Api.ApikeyCmd)
+		})
+	}
+}
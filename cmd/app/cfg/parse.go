@@ -1,11 +1,11 @@
 package cfg
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 
-	"github.com/BurntSushi/toml"
 	"github.com/Doom-z/RepClient/pkg/logger"
 )
 
@@ -17,37 +17,116 @@ func getAppDir() string {
 	return filepath.Dir(dir)
 }
 
-// loadConfValid loads the configuration from the given path.
-// If the path is empty, the defaultConfPath is used.
-// If the path is relative, the app executable dir is prepended.
-func LoadConfValid(configFileName string, defaultConf Conf, defaultConfPath string) Conf {
+// Sources records where the effective config came from.
+type Sources struct {
+	// File is the config file, empty when only the defaults are used
+	File string
+	// Profile is the profile applied over the config file
+	Profile string
+	// Env are the REPCLIENT_* variables applied on top of it
+	Env []string
+	// Apikey is the key the api key was read from: api_key, api_key_file or api_key_cmd
+	Apikey string
+}
+
+// LoadConfValid loads the layered config of configFileName and profile,
+// see Load, and exits on errors. Without strict the config is only layered,
+// see Layer, for commands that do not query the API. If configFileName is
+// empty, defaultConfPath is used.
+func LoadConfValid(configFileName, profile string, strict bool, defaultConf Conf, defaultConfPath string) Conf {
 	if configFileName == "" {
 		configFileName = defaultConfPath
 	}
-	path := findSuitablePath(configFileName)
+	load := Load
+	if !strict {
+		load = Layer
+	}
+	conf, sources, err := load(configFileName, profile, defaultConf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if sources.File == "" {
+		log.Printf("no config file %s found, using the defaults. create one with: %s config init", configFileName, Name)
+	}
+	return conf
+}
 
-	if path == "" {
-		f, createErr := os.Create(defaultConfPath)
-		if createErr != nil {
-			log.Fatalf("failed to create config file: %v", createErr)
-		}
-		defer f.Close()
+// Load layers defaultConf < the config file < its [profiles.<name>] table
+// when profile is set < REPCLIENT_* environment variables, validates the
+// result and resolves the api key. CLI flags are applied on top by the
+// commands using them.
+func Load(configFileName, profile string, defaultConf Conf) (Conf, Sources, error) {
+	conf, sources, err := Check(configFileName, profile, defaultConf)
+	if err != nil {
+		return conf, sources, err
+	}
+	if sources.Apikey, err = conf.ResolveSecrets(); err != nil {
+		return conf, sources, err
+	}
+	return conf, sources, nil
+}
 
-		encErr := toml.NewEncoder(f).Encode(defaultConf)
-		if encErr != nil {
-			log.Fatalf("failed to write default config to file: %v", encErr)
-		}
-		log.Printf("using default config. written to: %s", defaultConfPath)
+// Check layers the config like Load without resolving the api key, so no
+// credential helper is run. Unknown keys of the config file and invalid
+// values are returned as a *ValidationError, with the file line or
+// environment variable they came from.
+func Check(configFileName, profile string, defaultConf Conf) (Conf, Sources, error) {
+	conf, sources, err := Layer(configFileName, profile, defaultConf)
+	if err != nil {
+		return conf, sources, err
+	}
 
-		path = defaultConfPath
+	var problems []Problem
+	path := sources.File
+	if path != "" {
+		if problems, err = checkFile(path, defaultConf); err != nil {
+			return conf, sources, err
+		}
+	}
+	if err := conf.Validate(); err != nil {
+		problems = append(problems, err.(*ValidationError).Problems...)
 	}
+	if len(problems) > 0 {
+		locate(problems, path, conf.Profile, sources.Env)
+		return conf, sources, &ValidationError{Problems: problems}
+	}
+	return conf, sources, nil
+}
 
-	_, err := toml.DecodeFile(path, &defaultConf)
+// Layer layers the config like Check without validating it and without
+// resolving the api key, for commands that only read a few settings.
+func Layer(configFileName, profile string, defaultConf Conf) (Conf, Sources, error) {
+	conf, path, err := LoadFile(configFileName, profile, defaultConf)
 	if err != nil {
-		log.Fatalf("failed to load config from %s: %v", path, err)
+		return conf, Sources{}, err
 	}
+	sources := Sources{File: path, Profile: conf.Profile}
+	sources.Env, err = ApplyEnv(&conf, os.LookupEnv)
+	return conf, sources, err
+}
 
-	return defaultConf
+// LoadFile decodes the first config file found for configFileName over
+// defaultConf, applies profile when set, and returns the file path, which
+// is empty when there is none. The file is looked up in the current dir,
+// the app dir and $HOME/.config/<Name>.
+func LoadFile(configFileName, profile string, defaultConf Conf) (Conf, string, error) {
+	path := findSuitablePath(configFileName)
+	if path == "" {
+		if profile != "" {
+			return defaultConf, "", fmt.Errorf("profile %q needs a config file, %s not found", profile, configFileName)
+		}
+		return defaultConf, "", nil
+	}
+	file, md, err := decodeFile(path, defaultConf)
+	if err != nil {
+		return file.Conf, path, err
+	}
+	if profile != "" {
+		if err := file.applyProfile(md, profile, path); err != nil {
+			return file.Conf, path, err
+		}
+	}
+	return file.Conf, path, nil
 }
 
 func findSuitablePath(configFileName string) string {
@@ -0,0 +1,82 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// fileConf is the layout of a config file, a Conf plus the
+// [profiles.<name>] tables, which are decoded once a profile is selected.
+type fileConf struct {
+	Conf
+	Profiles map[string]toml.Primitive `toml:"profiles"`
+}
+
+// profileLayer are the sections a profile overrides. Keys missing in the
+// profile keep the value of the config file.
+type profileLayer struct {
+	Api    Api    `toml:"api"`
+	Output Output `toml:"output"`
+	Log    Log    `toml:"log"`
+}
+
+func decodeFile(path string, defaultConf Conf) (fileConf, toml.MetaData, error) {
+	file := fileConf{Conf: defaultConf}
+	md, err := toml.DecodeFile(path, &file)
+	if err != nil {
+		return file, md, fmt.Errorf("failed to load config from %s: %w", path, err)
+	}
+	return file, md, nil
+}
+
+// applyProfile decodes the profile name of file over its config.
+func (f *fileConf) applyProfile(md toml.MetaData, name, path string) error {
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s, available: %s", name, path, strings.Join(f.profileNames(), ", "))
+	}
+	layer := profileLayer{Api: f.Api, Output: f.Output, Log: f.Log}
+	if err := md.PrimitiveDecode(profile, &layer); err != nil {
+		return fmt.Errorf("invalid profile %q in %s: %w", name, path, err)
+	}
+	defined := func(key string) bool { return md.IsDefined("profiles", name, "api", key) }
+	layer.Api.useKeySources(defined("api_key"), defined("api_key_file"), defined("api_key_cmd"), defined("keys"))
+	f.Api, f.Output, f.Log = layer.Api, layer.Output, layer.Log
+	f.Profile = name
+	return nil
+}
+
+func (f *fileConf) profileNames() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profiles returns every profile of the config file of configFileName
+// applied over its config, sorted by name, and the file path.
+func Profiles(configFileName string, defaultConf Conf) ([]Conf, string, error) {
+	path := findSuitablePath(configFileName)
+	if path == "" {
+		return nil, "", fmt.Errorf("no config file %s found", configFileName)
+	}
+	file, md, err := decodeFile(path, defaultConf)
+	if err != nil {
+		return nil, path, err
+	}
+
+	var profiles []Conf
+	for _, name := range file.profileNames() {
+		f := file
+		if err := f.applyProfile(md, name, path); err != nil {
+			return nil, path, err
+		}
+		profiles = append(profiles, f.Conf)
+	}
+	return profiles, path, nil
+}
@@ -0,0 +1,144 @@
+package cfg
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profilesConf = `[api]
+host = "https://api.example.com"
+api_key_cmd = "pass show shared"
+rate_limit = 5.0
+
+[output]
+format = "csv"
+dir = "results"
+
+[profiles.paid.api]
+api_key = "paid-key"
+
+[profiles.staging.api]
+host = "https://staging.example.com"
+api_key_file = "staging.key"
+
+[profiles.staging.output]
+dir = "staging"
+
+[profiles.pool]
+api.keys = [{ key = "a" }, { key = "b", weight = 2 }]
+`
+
+func TestLoadFileProfile(t *testing.T) {
+	path := writeConf(t, profilesConf)
+	tests := []struct {
+		profile           string
+		host, key, keyCmd string
+		keyFile, dir      string
+	}{
+		{profile: "", host: "https://api.example.com", key: "@repproject", keyCmd: "pass show shared", dir: "results"},
+		{profile: "paid", host: "https://api.example.com", key: "paid-key", dir: "results"},
+		{profile: "staging", host: "https://staging.example.com", keyFile: "staging.key", dir: "staging"},
+		// a pool replaces the key command of the file
+		{profile: "pool", host: "https://api.example.com", dir: "results"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			conf, file, err := LoadFile(path, tt.profile, GetDefaultConf())
+			require.NoError(t, err)
+			assert.Equal(t, path, file)
+			assert.Equal(t, tt.profile, conf.Profile)
+			assert.Equal(t, tt.host, conf.Api.Host)
+			assert.Equal(t, tt.key, conf.Api.Apikey)
+			assert.Equal(t, tt.keyFile, conf.Api.ApikeyFile)
+			assert.Equal(t, tt.keyCmd, conf.Api.ApikeyCmd)
+			assert.Equal(t, tt.dir, conf.Output.Dir)
+			// keys the profile does not set keep the value of the file
+			assert.Equal(t, "csv", conf.Output.Format)
+			assert.Equal(t, 5.0, conf.Api.RateLimit)
+		})
+	}
+}
+
+func TestLoadFileUnknownProfile(t *testing.T) {
+	path := writeConf(t, profilesConf)
+	_, _, err := LoadFile(path, "home", GetDefaultConf())
+	assert.EqualError(t, err, fmt.Sprintf(`profile "home" not found in %s, available: paid, pool, staging`, path))
+
+	_, _, err = LoadFile(path+".missing", "paid", GetDefaultConf())
+	assert.EqualError(t, err, fmt.Sprintf(`profile "paid" needs a config file, %s.missing not found`, path))
+}
+
+const poolConf = `[api]
+host = "https://api.example.com"
+
+[[api.keys]]
+key = "pool-a"
+
+[[api.keys]]
+key = "pool-b"
+
+[profiles.single.api]
+api_key = "single-key"
+
+[profiles.cmd.api]
+api_key_cmd = "pass show key"
+
+[profiles.other.output]
+dir = "other"
+`
+
+func TestLoadFileProfileReplacesPool(t *testing.T) {
+	path := writeConf(t, poolConf)
+	tests := []struct {
+		profile, key, keyCmd string
+		keys                 int
+	}{
+		{profile: "single", key: "single-key"},
+		{profile: "cmd", keyCmd: "pass show key"},
+		{profile: "other", key: "@repproject", keys: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			conf, _, err := LoadFile(path, tt.profile, GetDefaultConf())
+			require.NoError(t, err)
+			assert.Equal(t, tt.key, conf.Api.Apikey)
+			assert.Equal(t, tt.keyCmd, conf.Api.ApikeyCmd)
+			assert.Len(t, conf.Api.Keys, tt.keys, "a profile key source replaces the pool of the file")
+		})
+	}
+}
+
+func TestLoadProfileBelowEnv(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := writeConf(t, profilesConf)
+	t.Setenv("REPCLIENT_OUTPUT_DIR", "env")
+
+	conf, sources, err := Load(path, "paid", GetDefaultConf())
+	require.NoError(t, err)
+	assert.Equal(t, "paid", sources.Profile)
+	assert.Equal(t, "env", conf.Output.Dir)
+	assert.Equal(t, "paid-key", conf.Api.Apikey)
+	assert.Equal(t, "api_key", sources.Apikey, "the key command of the file is not run")
+}
+
+func TestProfiles(t *testing.T) {
+	path := writeConf(t, profilesConf)
+	profiles, file, err := Profiles(path, GetDefaultConf())
+	require.NoError(t, err)
+	assert.Equal(t, path, file)
+
+	var names []string
+	for _, p := range profiles {
+		names = append(names, p.Profile)
+	}
+	assert.Equal(t, []string{"paid", "pool", "staging"}, names)
+	assert.Equal(t, "paid-key", profiles[0].Api.Apikey)
+	assert.Len(t, profiles[1].Api.Keys, 2)
+	assert.Equal(t, "staging", profiles[2].Output.Dir)
+
+	_, _, err = Profiles(path+".missing", GetDefaultConf())
+	assert.EqualError(t, err, fmt.Sprintf("no config file %s.missing found", path))
+}
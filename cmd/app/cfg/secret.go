@@ -0,0 +1,93 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// apiKeyCmdTimeout bounds how long a credential helper may run.
+const apiKeyCmdTimeout = 30 * time.Second
+
+// useKeySources keeps only the api key sources of a config layer that set
+// some of them, api_key, api_key_file, api_key_cmd or the keys pool, and
+// clears the others, so the layer replaces the api key of the layers below
+// instead of losing to their key file, command or pool.
+func (a *Api) useKeySources(apiKey, file, cmd, keys bool) {
+	if !apiKey && !file && !cmd && !keys {
+		return
+	}
+	if !apiKey {
+		a.Apikey = ""
+	}
+	if !file {
+		a.ApikeyFile = ""
+	}
+	if !cmd {
+		a.ApikeyCmd = ""
+	}
+	if !keys {
+		a.Keys = nil
+	}
+}
+
+// ResolveSecrets replaces api.api_key with the content of api.api_key_file
+// or the output of api.api_key_cmd when one of them is set, and returns the
+// key the api key came from.
+func (c *Conf) ResolveSecrets() (string, error) {
+	switch {
+	case c.Api.ApikeyFile != "" && c.Api.ApikeyCmd != "":
+		return "", errors.New("only one of api.api_key_file and api.api_key_cmd can be set")
+	case c.Api.ApikeyFile != "":
+		b, err := os.ReadFile(c.Api.ApikeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read api.api_key_file: %w", err)
+		}
+		key := strings.TrimSpace(string(b))
+		if key == "" {
+			return "", fmt.Errorf("api.api_key_file %s is empty", c.Api.ApikeyFile)
+		}
+		c.Api.Apikey = key
+		return "api_key_file", nil
+	case c.Api.ApikeyCmd != "":
+		key, err := runCredentialHelper(c.Api.ApikeyCmd)
+		if err != nil {
+			return "", fmt.Errorf("api.api_key_cmd: %w", err)
+		}
+		c.Api.Apikey = key
+		return "api_key_cmd", nil
+	}
+	return "api_key", nil
+}
+
+// runCredentialHelper runs command with the shell and returns its trimmed
+// stdout, stderr is passed through so helpers can prompt.
+func runCredentialHelper(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), apiKeyCmdTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(stdout.String())
+	if key == "" {
+		return "", errors.New("printed an empty key")
+	}
+	return key, nil
+}
@@ -0,0 +1,62 @@
+package cfg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSecrets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("credential helpers are run with sh")
+	}
+	dir := t.TempDir()
+	keyFile, emptyFile := filepath.Join(dir, "key"), filepath.Join(dir, "empty")
+	require.NoError(t, os.WriteFile(keyFile, []byte("  file-key\n"), 0600))
+	require.NoError(t, os.WriteFile(emptyFile, []byte("\n"), 0600))
+
+	tests := []struct {
+		name      string
+		api       Api
+		key, from string
+		err       string
+	}{
+		{name: "api key", api: Api{Apikey: "plain"}, key: "plain", from: "api_key"},
+		{name: "key file", api: Api{Apikey: "plain", ApikeyFile: keyFile}, key: "file-key", from: "api_key_file"},
+		{name: "key cmd", api: Api{Apikey: "plain", ApikeyCmd: "echo ' cmd-key '"}, key: "cmd-key", from: "api_key_cmd"},
+		{name: "both", api: Api{ApikeyFile: keyFile, ApikeyCmd: "echo key"}, err: "only one of api.api_key_file and api.api_key_cmd can be set"},
+		{name: "missing file", api: Api{ApikeyFile: filepath.Join(dir, "missing")}, err: "failed to read api.api_key_file: open " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{name: "empty file", api: Api{ApikeyFile: emptyFile}, err: "api.api_key_file " + emptyFile + " is empty"},
+		{name: "failing cmd", api: Api{ApikeyCmd: "exit 3"}, err: "api.api_key_cmd: exit status 3"},
+		{name: "silent cmd", api: Api{ApikeyCmd: "true"}, err: "api.api_key_cmd: printed an empty key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := Conf{Api: tt.api}
+			from, err := conf.ResolveSecrets()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.from, from)
+			assert.Equal(t, tt.key, conf.Api.Apikey)
+		})
+	}
+}
+
+func TestLoadEnvKeyWinsOverKeyCmd(t *testing.T) {
+	t.Chdir(t.TempDir())
+	path := writeConf(t, "[api]\napi_key_cmd = \"exit 1\"\n")
+	t.Setenv("REPCLIENT_API_API_KEY", "env-key")
+
+	conf, sources, err := Load(path, "", GetDefaultConf())
+	require.NoError(t, err, "the failing helper is not run")
+	assert.Equal(t, "env-key", conf.Api.Apikey)
+	assert.Equal(t, "api_key", sources.Apikey)
+	assert.Equal(t, []string{"REPCLIENT_API_API_KEY"}, sources.Env)
+}
@@ -0,0 +1,473 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Doom-z/RepClient/client"
+	"github.com/Doom-z/RepClient/pkg/graph"
+	"github.com/Doom-z/RepClient/pkg/linetmpl"
+	"github.com/Doom-z/RepClient/pkg/timefmt"
+)
+
+// OutputFormats are the supported output.format values.
+var OutputFormats = []string{"ndjson", "json", "csv", "txt", "geojson", "template"}
+
+// LogLevels are the supported log.level values.
+var LogLevels = []string{"trace", "debug", "info", "warn", "warning", "error", "fatal", "panic"}
+
+// Problem is an invalid or unknown config key.
+type Problem struct {
+	// Key is the toml path, with the index of array tables, e.g. log.file[0].path
+	Key string
+	// Where is the file:line or environment variable the value came from,
+	// empty for defaults
+	Where string
+	Msg   string
+}
+
+func (p Problem) String() string {
+	if p.Where == "" {
+		return fmt.Sprintf("%s: %s", p.Key, p.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.Where, p.Key, p.Msg)
+}
+
+// ValidationError lists every problem found in a config.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := []string{fmt.Sprintf("invalid config, %d problem(s):", len(e.Problems))}
+	for _, p := range e.Problems {
+		lines = append(lines, "  "+p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the values of the config: supported formats and levels,
+// urls, limits, that files to read exist and that directories to write
+// to are writable. It returns a *ValidationError listing every problem.
+func (c Conf) Validate() error {
+	var problems []Problem
+	add := func(key, format string, a ...any) {
+		problems = append(problems, Problem{Key: key, Msg: fmt.Sprintf(format, a...)})
+	}
+
+	if err := checkURL(c.Api.Host); err != nil {
+		add("api.host", "%v", err)
+	}
+	for i, host := range c.Api.Hosts {
+		name := fmt.Sprintf("api.hosts[%d]", i)
+		if err := checkURL(host.URL); err != nil {
+			add(name+".url", "%v", err)
+		}
+		if host.Priority < 0 {
+			add(name+".priority", "must not be negative")
+		}
+	}
+	if c.Api.HostMaxFailures < 0 {
+		add("api.host_max_failures", "must not be negative")
+	}
+	if c.Api.HostCoolDown < 0 {
+		add("api.host_cool_down", "must not be negative")
+	}
+	if c.Api.ApikeyFile != "" && c.Api.ApikeyCmd != "" {
+		add("api.api_key_cmd", "api_key_file and api_key_cmd are exclusive")
+	}
+	if c.Api.RateLimit < 0 {
+		add("api.rate_limit", "must not be negative")
+	}
+	if c.Api.RateLimit > 0 && c.Api.Burst < 1 {
+		add("api.burst", "must be at least 1 with a rate_limit")
+	}
+	for i, key := range c.Api.Keys {
+		name := fmt.Sprintf("api.keys[%d]", i)
+		if key.Key == "" {
+			add(name+".key", "must not be empty")
+		}
+		if key.Weight < 0 {
+			add(name+".weight", "must not be negative")
+		}
+		if key.DailyBudget < 0 {
+			add(name+".daily_budget", "must not be negative")
+		}
+	}
+	if len(c.Api.Keys) > 0 && c.Api.KeyUsage != "" {
+		if err := writableDir(filepath.Dir(c.Api.KeyUsage)); err != nil {
+			add("api.key_usage", "%v", err)
+		}
+	}
+	c.Api.Transport.validate(add)
+
+	o := c.Output
+	if !slices.Contains(OutputFormats, o.Format) {
+		add("output.format", "unsupported format %q, expected one of %s", o.Format, strings.Join(OutputFormats, ", "))
+	}
+	if o.Dir == "" {
+		add("output.dir", "must not be empty")
+	} else if err := writableDir(o.Dir); err != nil {
+		add("output.dir", "%v", err)
+	}
+	if ext := filepath.Ext(o.Summary); o.Summary != "" && ext != ".json" && ext != ".ndjson" {
+		add("output.summary", "must be a .json or .ndjson file")
+	}
+	if _, err := timefmt.ParseMode(o.Timestamp); err != nil {
+		add("output.timestamp", "%v", err)
+	}
+	if o.Format == "template" || o.Template != "" {
+		if _, err := linetmpl.Parse(o.Template); err != nil {
+			add("output.template", "%v", err)
+		}
+	}
+	if o.Graph != "" && graph.FormatFromPath(o.Graph) == "" {
+		add("output.graph", "unsupported extension %q, expected one of .%s", filepath.Ext(o.Graph), strings.Join(graph.Formats, ", ."))
+	}
+	if o.Webhook.URL != "" {
+		if err := checkURL(o.Webhook.URL); err != nil {
+			add("output.webhook.url", "%v", err)
+		}
+	}
+	if o.Webhook.MaxRetries < 0 {
+		add("output.webhook.max_retries", "must not be negative")
+	}
+	if o.Webhook.Timeout < 0 {
+		add("output.webhook.timeout", "must not be negative")
+	}
+
+	if !slices.Contains(LogLevels, c.Log.Level) {
+		add("log.level", "unknown level %q, expected one of %s", c.Log.Level, strings.Join(LogLevels, ", "))
+	}
+	for i, s := range c.Log.Stdout {
+		key := fmt.Sprintf("log.stdout[%d]", i)
+		if err := checkLogFormat(s.Format); err != nil {
+			add(key+".format", "%v", err)
+		}
+		if s.Output != LogOutputStdout && s.Output != LogOutputStderr {
+			add(key+".output", "unknown output %q, expected stdout or stderr", s.Output)
+		}
+	}
+	for i, f := range c.Log.File {
+		key := fmt.Sprintf("log.file[%d]", i)
+		if err := checkLogFormat(f.Format); err != nil {
+			add(key+".format", "%v", err)
+		}
+		if f.Path == "" {
+			add(key+".path", "must not be empty")
+		} else if err := writableDir(filepath.Dir(f.Path)); err != nil {
+			add(key+".path", "%v", err)
+		}
+	}
+
+	if c.Watch.StateDir == "" {
+		add("watch.state_dir", "must not be empty")
+	} else if err := writableDir(c.Watch.StateDir); err != nil {
+		add("watch.state_dir", "%v", err)
+	}
+	for _, spec := range c.Watch.Notify {
+		kind, value, _ := strings.Cut(spec, ":")
+		switch {
+		case kind == "stdout":
+		case kind == "file" && value != "":
+		case kind == "webhook" && (value != "" || o.Webhook.URL != ""):
+		default:
+			add("watch.notify", "invalid notifier %q, expected stdout, file:PATH or webhook:URL", spec)
+		}
+	}
+
+	for key, paths := range map[string][]string{"enrich.mmdb": c.Enrich.MMDB, "enrich.ip2asn": c.Enrich.IP2ASN} {
+		for _, path := range paths {
+			if _, err := os.Stat(path); err != nil {
+				add(key, "%v", err)
+			}
+		}
+	}
+
+	if c.Usage.Ledger != "" {
+		if err := writableDir(filepath.Dir(c.Usage.Ledger)); err != nil {
+			add("usage.ledger", "%v", err)
+		}
+	}
+	if c.Usage.MaxRequestsPerDay < 0 {
+		add("usage.max_requests_per_day", "must not be negative")
+	}
+	if c.Usage.SoftRequestsPerDay < 0 {
+		add("usage.soft_requests_per_day", "must not be negative")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	slices.SortStableFunc(problems, func(a, b Problem) int { return strings.Compare(a.Key, b.Key) })
+	return &ValidationError{Problems: problems}
+}
+
+func checkURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https url", raw)
+	}
+	return nil
+}
+
+func checkLogFormat(format LogFormat) error {
+	if format != LogFormatJSON && format != LogFormatText {
+		return fmt.Errorf("unknown format %q, expected json or text", format)
+	}
+	return nil
+}
+
+// writableDir reports whether files can be created in dir, or in its
+// nearest existing parent when dir does not exist yet.
+func writableDir(dir string) error {
+	for {
+		info, err := os.Stat(dir)
+		if errors.Is(err, fs.ErrNotExist) && filepath.Dir(dir) != dir {
+			dir = filepath.Dir(dir)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		f, err := os.CreateTemp(dir, "."+Name+"-*")
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %w", dir, err)
+		}
+		f.Close()
+		return os.Remove(f.Name())
+	}
+}
+
+// checkFile reports the keys of the config file at path that match no
+// config field, in the file itself and in every profile.
+func checkFile(path string, defaultConf Conf) ([]Problem, error) {
+	file, md, err := decodeFile(path, defaultConf)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range file.profileNames() {
+		f := file
+		if err := f.applyProfile(md, name, path); err != nil {
+			return nil, err
+		}
+	}
+
+	var problems []Problem
+	for _, key := range md.Undecoded() {
+		msg := "unknown key"
+		if valid := validKeys(key[:len(key)-1]); len(valid) > 0 {
+			msg += ", expected one of " + strings.Join(valid, ", ")
+		}
+		problems = append(problems, Problem{Key: key.String(), Msg: msg})
+	}
+	return problems, nil
+}
+
+// validKeys returns the keys of the table at path, e.g. [output webhook].
+func validKeys(path []string) []string {
+	t := reflect.TypeOf(fileConf{})
+	if len(path) >= 2 && path[0] == "profiles" {
+		t, path = reflect.TypeOf(profileLayer{}), path[2:]
+	}
+	for _, name := range path {
+		field, ok := tomlField(t, name)
+		if !ok {
+			return nil
+		}
+		t = field.Type
+		if t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+	}
+
+	var keys []string
+	for _, field := range reflect.VisibleFields(t) {
+		if key := tomlKey(field); key != "" && key != "profiles" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func tomlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for _, field := range reflect.VisibleFields(t) {
+		if tomlKey(field) == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func tomlKey(field reflect.StructField) string {
+	if field.Anonymous {
+		return ""
+	}
+	key, _, _ := strings.Cut(field.Tag.Get("toml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// locate sets where the value of every problem came from: the environment
+// variable overriding it, the profile or the file line defining it.
+func locate(problems []Problem, path, profile string, env []string) {
+	var lines map[string]int
+	if data, err := os.ReadFile(path); err == nil {
+		lines = keyLines(string(data))
+	}
+	for i, p := range problems {
+		name := EnvPrefix + strings.ToUpper(strings.ReplaceAll(indexPattern.ReplaceAllString(p.Key, ""), ".", "_"))
+		if slices.Contains(env, name) {
+			problems[i].Where = name
+			continue
+		}
+		keys := []string{p.Key}
+		if profile != "" {
+			keys = []string{"profiles." + profile + "." + p.Key, p.Key}
+		}
+		for _, key := range keys {
+			if line, ok := lines[key]; ok {
+				problems[i].Where = fmt.Sprintf("%s:%d", path, line)
+				break
+			}
+		}
+	}
+}
+
+var indexPattern = regexp.MustCompile(`\[\d+\]`)
+
+// keyLines maps the keys and tables of a toml document to the line they
+// are defined on. Keys in array tables are mapped with and without their
+// index, e.g. log.file[1].path and log.file.path for the first one.
+func keyLines(data string) map[string]int {
+	lines := map[string]int{}
+	set := func(key string, line int) {
+		if _, ok := lines[key]; !ok {
+			lines[key] = line
+		}
+		if plain := indexPattern.ReplaceAllString(key, ""); plain != key {
+			if _, ok := lines[plain]; !ok {
+				lines[plain] = line
+			}
+		}
+	}
+
+	table := ""
+	arrays := map[string]int{}
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "[["):
+			end := strings.Index(line, "]]")
+			if end < 0 {
+				continue
+			}
+			name := normalizeKey(line[2:end])
+			table = fmt.Sprintf("%s[%d]", name, arrays[name])
+			arrays[name]++
+			set(table, i+1)
+		case strings.HasPrefix(line, "["):
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			// tables inside an array table belong to its last element
+			name := normalizeKey(line[1:end])
+			for array, n := range arrays {
+				if strings.HasPrefix(name, array+".") {
+					name = fmt.Sprintf("%s[%d]%s", array, n-1, strings.TrimPrefix(name, array))
+				}
+			}
+			table = name
+			set(table, i+1)
+		default:
+			key, _, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			full := normalizeKey(key)
+			if table != "" {
+				full = table + "." + full
+			}
+			set(full, i+1)
+		}
+	}
+	return lines
+}
+
+// normalizeKey removes the spaces and quotes around the parts of a dotted key.
+func normalizeKey(key string) string {
+	parts := strings.Split(key, ".")
+	for i, part := range parts {
+		parts[i] = strings.Trim(strings.TrimSpace(part), `"'`)
+	}
+	return strings.Join(parts, ".")
+}
+
+func (t Transport) validate(add func(key, format string, a ...any)) {
+	if t.Proxy != "" {
+		u, err := url.Parse(t.Proxy)
+		switch {
+		case err != nil:
+			add("api.transport.proxy", "%v", err)
+		case !slices.Contains([]string{"http", "https", "socks5", "socks5h"}, u.Scheme) || u.Host == "":
+			add("api.transport.proxy", "%q is not an http, https, socks5 or socks5h url", u.Redacted())
+		}
+	}
+	if _, ok := client.TLSVersions[t.MinTLSVersion]; t.MinTLSVersion != "" && !ok {
+		add("api.transport.min_tls_version", "unsupported version %q, expected 1.0, 1.1, 1.2 or 1.3", t.MinTLSVersion)
+	}
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		add("api.transport.cert_file", "cert_file and key_file must be set together")
+	}
+	for key, path := range map[string]string{"ca_bundle": t.CABundle, "cert_file": t.CertFile, "key_file": t.KeyFile} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			add("api.transport."+key, "%v", err)
+		}
+	}
+	for key, d := range map[string]time.Duration{
+		"timeout":                 t.Timeout,
+		"dial_timeout":            t.DialTimeout,
+		"tls_handshake_timeout":   t.TLSHandshakeTimeout,
+		"response_header_timeout": t.ResponseHeaderTimeout,
+		"idle_conn_timeout":       t.IdleConnTimeout,
+	} {
+		if d < 0 {
+			add("api.transport."+key, "must not be negative")
+		}
+	}
+	for key, n := range map[string]int{
+		"max_idle_conns":          t.MaxIdleConns,
+		"max_idle_conns_per_host": t.MaxIdleConnsPerHost,
+		"max_conns_per_host":      t.MaxConnsPerHost,
+	} {
+		if n < 0 {
+			add("api.transport."+key, "must not be negative")
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package cfg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConf writes data as a config file into a temp dir and returns its path.
+func writeConf(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+// problems returns the problems of err by key, formatted as "where: msg".
+func problems(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var verr *ValidationError
+	require.True(t, errors.As(err, &verr), "expected a *ValidationError, got %v", err)
+	found := map[string]string{}
+	for _, p := range verr.Problems {
+		found[p.Key] = p.Where + ": " + p.Msg
+	}
+	return found
+}
+
+func TestCheckUnknownKeys(t *testing.T) {
+	path := writeConf(t, `[api]
+host = "https://api.example.com"
+hots = "https://typo.example.com"
+
+[output]
+formt = "csv"
+
+[profiles.work.api]
+api_kye = "secret"
+`)
+	tests := []struct {
+		name, profile string
+	}{
+		{name: "without profile"},
+		// every profile is checked, not only the selected one
+		{name: "other profile", profile: "work"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := Check(path, tt.profile, GetDefaultConf())
+			found := problems(t, err)
+			require.Len(t, found, 3)
+			assert.Regexp(t, `config.toml:3: unknown key, expected one of host, hosts, `, found["api.hots"])
+			assert.Regexp(t, `config.toml:6: unknown key, expected one of format, dir, `, found["output.formt"])
+			assert.Regexp(t, `config.toml:9: unknown key, expected one of host, hosts, `, found["profiles.work.api.api_kye"])
+		})
+	}
+}
+
+func TestCheckLocatesValues(t *testing.T) {
+	path := writeConf(t, `[output]
+format = "xml"
+
+[[log.stdout]]
+format = "text"
+output = "stdout"
+
+[[log.stdout]]
+format = "yaml"
+output = "stdout"
+
+[profiles.dev.log]
+level = "loud"
+`)
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "file lines",
+			want: map[string]string{
+				"output.format":              path + ":2",
+				"log.stdout[1].format":       path + ":9",
+				"api.transport.proxy":        "",
+				"usage.max_requests_per_day": "",
+			},
+		},
+		{
+			name:    "profile line",
+			profile: "dev",
+			want:    map[string]string{"log.level": path + ":13", "output.format": path + ":2"},
+		},
+		{
+			name: "environment",
+			env:  map[string]string{"REPCLIENT_OUTPUT_FORMAT": "yaml", "REPCLIENT_API_TRANSPORT_PROXY": "ftp://proxy", "REPCLIENT_USAGE_MAX_REQUESTS_PER_DAY": "-1"},
+			want: map[string]string{
+				"output.format":              "REPCLIENT_OUTPUT_FORMAT",
+				"api.transport.proxy":        "REPCLIENT_API_TRANSPORT_PROXY",
+				"usage.max_requests_per_day": "REPCLIENT_USAGE_MAX_REQUESTS_PER_DAY",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			_, _, err := Check(path, tt.profile, GetDefaultConf())
+			var verr *ValidationError
+			require.ErrorAs(t, err, &verr)
+			where := map[string]string{}
+			for _, p := range verr.Problems {
+				where[p.Key] = p.Where
+			}
+			for key, want := range tt.want {
+				if want == "" {
+					assert.NotContains(t, where, key)
+					continue
+				}
+				assert.Equal(t, want, where[key], key)
+			}
+		})
+	}
+}
+
+func TestCheckUnknownProfile(t *testing.T) {
+	path := writeConf(t, "[profiles.work.api]\nhost = \"https://work.example.com\"\n")
+	_, _, err := Check(path, "home", GetDefaultConf())
+	assert.EqualError(t, err, fmt.Sprintf(`profile "home" not found in %s, available: work`, path))
+}
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	missing, keyFile := filepath.Join(dir, "missing"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, nil, 0600))
+	tests := []struct {
+		key    string
+		modify func(c *Conf)
+	}{
+		{"api.host", func(c *Conf) { c.Api.Host = "ftp://api.example.com" }},
+		{"api.hosts[0].url", func(c *Conf) { c.Api.Hosts = []ApiHost{{URL: "api.example.com"}} }},
+		{"api.hosts[0].priority", func(c *Conf) { c.Api.Hosts = []ApiHost{{URL: "https://api.example.com", Priority: -1}} }},
+		{"api.host_max_failures", func(c *Conf) { c.Api.HostMaxFailures = -1 }},
+		{"api.host_cool_down", func(c *Conf) { c.Api.HostCoolDown = -time.Second }},
+		{"api.api_key_cmd", func(c *Conf) { c.Api.ApikeyFile, c.Api.ApikeyCmd = "key.txt", "pass show key" }},
+		{"api.rate_limit", func(c *Conf) { c.Api.RateLimit = -1 }},
+		{"api.burst", func(c *Conf) { c.Api.RateLimit, c.Api.Burst = 5, 0 }},
+		{"api.keys[0].key", func(c *Conf) { c.Api.Keys = []ApiKey{{}} }},
+		{"api.keys[0].weight", func(c *Conf) { c.Api.Keys = []ApiKey{{Key: "k", Weight: -1}} }},
+		{"api.keys[0].daily_budget", func(c *Conf) { c.Api.Keys = []ApiKey{{Key: "k", DailyBudget: -1}} }},
+		{"api.transport.proxy", func(c *Conf) { c.Api.Transport.Proxy = "ftp://proxy.example.com" }},
+		{"api.transport.min_tls_version", func(c *Conf) { c.Api.Transport.MinTLSVersion = "1.4" }},
+		{"api.transport.cert_file", func(c *Conf) { c.Api.Transport.KeyFile = keyFile }},
+		{"api.transport.ca_bundle", func(c *Conf) { c.Api.Transport.CABundle = missing }},
+		{"api.transport.timeout", func(c *Conf) { c.Api.Transport.Timeout = -time.Second }},
+		{"api.transport.max_conns_per_host", func(c *Conf) { c.Api.Transport.MaxConnsPerHost = -1 }},
+		{"output.format", func(c *Conf) { c.Output.Format = "xml" }},
+		{"output.dir", func(c *Conf) { c.Output.Dir = "" }},
+		{"output.summary", func(c *Conf) { c.Output.Summary = "summary.txt" }},
+		{"output.timestamp", func(c *Conf) { c.Output.Timestamp = "unix" }},
+		{"output.template", func(c *Conf) { c.Output.Format, c.Output.Template = "template", "{{.Missing" }},
+		{"output.graph", func(c *Conf) { c.Output.Graph = "graph.png" }},
+		{"output.webhook.url", func(c *Conf) { c.Output.Webhook.URL = "hooks.example.com" }},
+		{"output.webhook.max_retries", func(c *Conf) { c.Output.Webhook.MaxRetries = -1 }},
+		{"output.webhook.timeout", func(c *Conf) { c.Output.Webhook.Timeout = -time.Second }},
+		{"log.level", func(c *Conf) { c.Log.Level = "loud" }},
+		{"log.stdout[0].format", func(c *Conf) { c.Log.Stdout[0].Format = "yaml" }},
+		{"log.stdout[0].output", func(c *Conf) { c.Log.Stdout[0].Output = LogOutputFile }},
+		{"log.file[0].path", func(c *Conf) { c.Log.File = []File{{Format: LogFormatText}} }},
+		{"watch.state_dir", func(c *Conf) { c.Watch.StateDir = "" }},
+		{"watch.notify", func(c *Conf) { c.Watch.Notify = []string{"webhook"} }},
+		{"enrich.mmdb", func(c *Conf) { c.Enrich.MMDB = []string{missing} }},
+		{"usage.max_requests_per_day", func(c *Conf) { c.Usage.MaxRequestsPerDay = -1 }},
+		{"usage.soft_requests_per_day", func(c *Conf) { c.Usage.SoftRequestsPerDay = -1 }},
+	}
+
+	t.Chdir(t.TempDir())
+	require.NoError(t, GetDefaultConf().Validate(), "the defaults are valid")
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			c := GetDefaultConf()
+			tt.modify(&c)
+			found := problems(t, c.Validate())
+			assert.Len(t, found, 1, "%v", found)
+			assert.Contains(t, found, tt.key)
+		})
+	}
+}
+
+func TestWritableDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, writableDir(filepath.Join(dir, "not", "created", "yet")))
+
+	file := filepath.Join(dir, "file")
+	require.NoError(t, os.WriteFile(file, nil, 0644))
+	assert.ErrorContains(t, writableDir(file), "is not a directory")
+}
+
+func TestKeyLines(t *testing.T) {
+	lines := keyLines(`# comment
+[api]
+host = "https://api.example.com"
+"api_key" = "key"
+
+[[api.keys]]
+key = "a"
+
+[[api.keys]]
+key = "b"
+
+[profiles.work]
+output.format = "csv"
+`)
+	assert.Equal(t, map[string]int{
+		"api":                         2,
+		"api.host":                    3,
+		"api.api_key":                 4,
+		"api.keys[0]":                 6,
+		"api.keys":                    6,
+		"api.keys[0].key":             7,
+		"api.keys.key":                7,
+		"api.keys[1]":                 9,
+		"api.keys[1].key":             10,
+		"profiles.work":               12,
+		"profiles.work.output.format": 13,
+	}, lines)
+}
@@ -0,0 +1,49 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/pkg/cidr"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Cidr collapses the IPs of the output files of cmd into prefixes.
+func Cidr(cmd args.CidrCmd) error {
+	if !slices.Contains(cidr.Formats, cmd.Format) {
+		return fmt.Errorf("unsupported cidr format: %s", cmd.Format)
+	}
+
+	set := cidr.NewSet()
+	for _, path := range cmd.Paths {
+		records, err := fileutil.LoadRecords(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		for _, record := range records {
+			if err := set.AddRecord(record); err != nil {
+				logger.Warnf("skipping record of %s: %v", path, err)
+			}
+		}
+	}
+	prefixes := set.Prefixes(cmd.ByASN)
+	logger.WithFields(map[string]any{
+		"ips":      set.Len(),
+		"prefixes": len(prefixes),
+	}).Debug("IPs collapsed")
+
+	var w io.Writer = os.Stdout
+	if cmd.Out != "" {
+		f, err := os.Create(cmd.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return cidr.Write(w, prefixes, cmd.Format)
+}
@@ -0,0 +1,14 @@
+package app
+
+import (
+	"os"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/completion"
+)
+
+// Completion prints the completion script of the shell of cmd.
+func Completion(cmd args.CompletionCmd) error {
+	return completion.Write(os.Stdout, completion.FromStruct(cfg.Name, &args.Args{}), cmd.Shell)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/toml"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/utils"
+)
+
+// ConfigShow prints the config of configPath and profile as toml with its
+// secrets redacted. With --effective every layer a run uses is applied.
+func ConfigShow(cmd args.ConfigShowCmd, configPath, profile string) error {
+	var conf cfg.Conf
+	var sources cfg.Sources
+	var err error
+	if cmd.Effective {
+		conf, sources, err = cfg.Load(configPath, profile, cfg.GetDefaultConf())
+	} else {
+		conf, sources.File, err = cfg.LoadFile(configPath, profile, cfg.GetDefaultConf())
+	}
+	if err != nil {
+		return err
+	}
+
+	if sources.File == "" {
+		fmt.Printf("# no config file %s found, using the defaults\n", configPath)
+	} else {
+		fmt.Printf("# config file: %s\n", sources.File)
+	}
+	if conf.Profile != "" {
+		fmt.Printf("# profile: %s\n", conf.Profile)
+	}
+	if cmd.Effective {
+		if len(sources.Env) > 0 {
+			fmt.Printf("# environment: %s\n", strings.Join(sources.Env, ", "))
+		}
+		fmt.Printf("# api key from: %s\n", sources.Apikey)
+	}
+	fmt.Println()
+	return toml.NewEncoder(os.Stdout).Encode(conf.Redacted())
+}
+
+// ConfigInit writes the default config to the path of cmd, or to
+// configPath when it has none.
+func ConfigInit(cmd args.ConfigInitCmd, configPath string) error {
+	path := cmd.Path
+	if path == "" {
+		path = configPath
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if cmd.Force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(cfg.GetDefaultConf()); err != nil {
+		return fmt.Errorf("failed to write default config: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "default config written to %s\n", path)
+	return nil
+}
+
+// ConfigValidate checks the config a run with profile would use, without
+// running api_key_cmd, and prints the problems found.
+func ConfigValidate(configPath, profile string) error {
+	_, sources, err := cfg.Check(configPath, profile, cfg.GetDefaultConf())
+	if err != nil {
+		return err
+	}
+	name := sources.File
+	if name == "" {
+		name = "defaults (no config file " + configPath + ")"
+	}
+	if sources.Profile != "" {
+		name += " profile " + sources.Profile
+	}
+	fmt.Printf("%s: ok\n", name)
+	return nil
+}
+
+// ConfigProfiles lists the profiles of the config file of configPath with
+// the host and redacted api key they use.
+func ConfigProfiles(configPath string) error {
+	profiles, path, err := cfg.Profiles(configPath, cfg.GetDefaultConf())
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		fmt.Printf("# no profiles in %s\n", path)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tHOST\tAPI KEY\tFORMAT\tDIR")
+	for _, p := range profiles {
+		apikey := utils.RedactSecret(p.Api.Apikey)
+		switch {
+		case len(p.Api.Keys) > 0:
+			apikey = fmt.Sprintf("pool of %d", len(p.Api.Keys))
+		case p.Api.ApikeyFile != "":
+			apikey = "file " + p.Api.ApikeyFile
+		case p.Api.ApikeyCmd != "":
+			apikey = "cmd"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Profile, p.Api.Host, apikey, p.Output.Format, p.Output.Dir)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/timefmt"
+)
+
+// Convert loads the records of the output files of cmd and writes them in
+// the format of the --out extension.
+func Convert(cmd args.ConvertCmd) error {
+	var mode timefmt.Mode
+	if cmd.Timestamp != "" {
+		var err error
+		if mode, err = timefmt.ParseMode(cmd.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	var records []map[string]any
+	for _, path := range cmd.Paths {
+		loaded, err := fileutil.LoadRecords(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		records = append(records, loaded...)
+	}
+	if mode != "" {
+		for _, record := range records {
+			mode.Apply(record)
+		}
+	}
+
+	var data any = records
+	if strings.ToLower(filepath.Ext(cmd.Out)) == ".txt" {
+		domains := make([]string, 0, len(records))
+		for _, record := range records {
+			if domain, ok := record["domain_id"].(string); ok && domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+		data = domains
+	}
+
+	if err := fileutil.EnsureDir(filepath.Dir(cmd.Out)); err != nil {
+		return err
+	}
+	if err := fileutil.SaveData(data, cmd.Out, "overwrite"); err != nil {
+		return err
+	}
+	logger.Infof("Converted %d records to %s", len(records), cmd.Out)
+	return nil
+}
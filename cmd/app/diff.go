@@ -0,0 +1,61 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/pkg/diff"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Diff compares the two result sets of cmd and writes the events as a
+// human summary or as ndjson.
+func Diff(cmd args.DiffCmd) error {
+	old, err := fileutil.LoadRecords(cmd.Old)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", cmd.Old, err)
+	}
+	new, err := fileutil.LoadRecords(cmd.New)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %w", cmd.New, err)
+	}
+
+	events := diff.Compare(old, new, diff.Options{Keys: cmd.Keys, Fields: cmd.Fields})
+	stats := diff.Summarize(events)
+	logger.WithFields(map[string]any{
+		"old":     len(old),
+		"new":     len(new),
+		"added":   stats.Added,
+		"removed": stats.Removed,
+		"changed": stats.Changed,
+	}).Debug("Diff computed")
+
+	var w io.Writer = os.Stdout
+	if cmd.Out != "" {
+		f, err := os.Create(cmd.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch cmd.Format {
+	case "summary":
+		return diff.WriteSummary(w, events)
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported diff format: %s", cmd.Format)
+	}
+}
@@ -1,16 +1,102 @@
 package app
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/Doom-z/RepClient/cmd/app/args"
 	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/cmd/app/log"
 	"github.com/Doom-z/RepClient/internal/run"
 	"github.com/Doom-z/RepClient/pkg/logger"
 )
 
+// HandleWithoutConf runs the subcommands that load the config themselves or
+// not at all, and reports whether args selected one of them. The offline
+// commands working on output files log to stderr, leaving stdout to their
+// reports.
+func HandleWithoutConf(args args.Args) bool {
+	if args.Diff != nil || args.Stats != nil || args.Cidr != nil || args.Convert != nil {
+		log.InitLogger(cfg.Log{
+			Level:  "info",
+			Stdout: []cfg.Stdout{{Format: cfg.LogFormatText, Output: cfg.LogOutputStderr}},
+		}, args.Verbose)
+	}
+
+	var err error
+	switch {
+	case args.Diff != nil:
+		err = Diff(*args.Diff)
+	case args.Stats != nil:
+		err = Stats(*args.Stats)
+	case args.Cidr != nil:
+		err = Cidr(*args.Cidr)
+	case args.Convert != nil:
+		err = Convert(*args.Convert)
+	case args.Complete != nil:
+		err = Completion(*args.Complete)
+	case args.ConfigCmd != nil && args.ConfigCmd.Init != nil:
+		err = ConfigInit(*args.ConfigCmd.Init, args.Config)
+	case args.ConfigCmd != nil && args.ConfigCmd.Show != nil:
+		err = ConfigShow(*args.ConfigCmd.Show, args.Config, args.ProfileName)
+	case args.ConfigCmd != nil && args.ConfigCmd.Profiles != nil:
+		err = ConfigProfiles(args.Config)
+	case args.ConfigCmd != nil && args.ConfigCmd.Validate != nil:
+		err = ConfigValidate(args.Config, args.ProfileName)
+	default:
+		return false
+	}
+	if err != nil {
+		// the logger is not set up without a config
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+	return true
+}
+
 func Handle(args args.Args, conf cfg.Conf) {
-	run, err := run.NewRun(args, conf)
+	if args.Usage != nil {
+		if err := Usage(*args.Usage, conf); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	opts, ok := args.Options()
+	if !ok {
+		logger.Fatal("no subcommand given")
+	}
+	if conf.Trial() {
+		opts.Trial = true
+	}
+	run, err := run.NewRun(opts, conf)
 	if err != nil {
 		logger.Fatal(err)
 	}
-	run.Start()
+
+	switch {
+	case args.Watch != nil:
+		status, err := run.Watch(*args.Watch)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		exit(status)
+	case args.Pivot != nil:
+		exit(run.Pivot(*args.Pivot))
+	case args.Query != nil:
+		exit(run.Query())
+	case args.Stream != nil:
+		exit(run.Stream())
+	case args.Bulk != nil:
+		exit(run.Bulk())
+	case args.Profile != nil:
+		exit(run.Profile())
+	}
+}
+
+// exit terminates the process with the exit code of status, if it is not 0.
+func exit(status run.ExitStatus) {
+	if code := status.Code(); code != 0 {
+		os.Exit(code)
+	}
 }
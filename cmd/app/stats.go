@@ -0,0 +1,43 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/stats"
+)
+
+// Stats counts the records of the output files of cmd and writes the report.
+func Stats(cmd args.StatsCmd) error {
+	if !slices.Contains(stats.Formats, cmd.Format) {
+		return fmt.Errorf("unsupported stats format: %s", cmd.Format)
+	}
+	agg, err := stats.New(cmd.By)
+	if err != nil {
+		return err
+	}
+	for _, path := range cmd.Paths {
+		records, err := fileutil.LoadRecords(path)
+		if err != nil {
+			return fmt.Errorf("failed to load %s: %w", path, err)
+		}
+		for _, record := range records {
+			agg.Add(record)
+		}
+	}
+
+	var w io.Writer = os.Stdout
+	if cmd.Out != "" {
+		f, err := os.Create(cmd.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return agg.Report(cmd.Top).Write(w, cmd.Format)
+}
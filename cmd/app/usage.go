@@ -0,0 +1,41 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/usage"
+)
+
+// Usage prints the totals of the usage ledger of conf per period.
+func Usage(cmd args.UsageCmd, conf cfg.Conf) error {
+	if conf.Usage.Ledger == "" {
+		return fmt.Errorf("usage.ledger is not set, no usage is recorded")
+	}
+	entries, err := usage.Ledger{Path: conf.Usage.Ledger}.Load()
+	if err != nil {
+		return err
+	}
+	totals, err := usage.Summarize(entries, cmd.Period, cmd.By, cmd.Last)
+	if err != nil {
+		return err
+	}
+	if err := usage.WriteTotals(os.Stdout, totals, cmd.By, cmd.Format); err != nil {
+		return err
+	}
+
+	if cmd.Format != "table" {
+		return nil
+	}
+	// the totals of today against the limits
+	today := usage.NewMeter(usage.Limits{}, entries).Today()
+	if limit := conf.Usage.MaxRequestsPerDay; limit > 0 {
+		fmt.Printf("\ntoday: %d of %d requests (usage.max_requests_per_day)\n", today.Requests, limit)
+	}
+	if conf.Trial() {
+		fmt.Printf("\ntoday: %d of %d trial records\n", today.Records, usage.TrialRecords)
+	}
+	return nil
+}
@@ -13,18 +13,27 @@ func main() {
 	var conf cfg.Conf
 	var defaultConf = cfg.GetDefaultConf()
 	args = LoadArgsValid()
-	conf = cfg.LoadConfValid(args.Config, defaultConf, "config.toml")
-	log.InitLogger(conf.Log, args.Verbose)
-
-	if conf.Api.Apikey == "@repproject" {
-		args.Trial = true
+	if app.HandleWithoutConf(args) {
+		return
 	}
+	// only the commands querying the API need a fully valid config
+	strict := args.Usage == nil
+	conf = cfg.LoadConfValid(args.Config, args.ProfileName, strict, defaultConf, "config.toml")
+	log.InitLogger(conf.Log, args.Verbose)
 
 	app.Handle(args, conf)
 }
 
+// LoadArgsValid parses the command line, a missing subcommand or invalid
+// flags of the subcommand print its usage and exit.
 func LoadArgsValid() args.Args {
 	var args args.Args
-	arg.MustParse(&args)
+	p := arg.MustParse(&args)
+	if p.Subcommand() == nil {
+		p.Fail("a subcommand is required")
+	}
+	if err := args.Validate(); err != nil {
+		_ = p.FailSubcommand(err.Error(), p.SubcommandNames()...)
+	}
 	return args
 }
@@ -0,0 +1,135 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/diff"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/webhook"
+)
+
+// Batch is the set of changes found for one target in one watch cycle.
+type Batch struct {
+	Target string       `json:"target"`
+	Time   time.Time    `json:"time"`
+	Stats  diff.Stats   `json:"stats"`
+	Events []diff.Event `json:"events"`
+}
+
+func NewBatch(target string, events []diff.Event) Batch {
+	return Batch{
+		Target: target,
+		Time:   time.Now().UTC(),
+		Stats:  diff.Summarize(events),
+		Events: events,
+	}
+}
+
+type Notifier interface {
+	Notify(batch Batch) error
+}
+
+// Parse creates a notifier from a spec:
+//   - stdout: human readable summary on stdout
+//   - file:PATH: events appended to PATH, one json object per line
+//   - webhook:URL: events POSTed as one batch to URL, using hook for
+//     signing, templating and retries. "webhook" alone uses hook.URL.
+func Parse(spec string, hook webhook.Config) (Notifier, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "stdout":
+		return &Stdout{out: os.Stdout}, nil
+	case "file":
+		if value == "" {
+			return nil, fmt.Errorf("notifier %q: missing file path", spec)
+		}
+		return &File{Path: value}, nil
+	case "webhook":
+		if value != "" {
+			hook.URL = value
+		}
+		if hook.URL == "" {
+			return nil, fmt.Errorf("notifier %q: missing url", spec)
+		}
+		// every batch is sent right away, nothing is left to flush
+		hook.FlushInterval = 0
+		sender, err := webhook.New(hook, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &Webhook{sender: sender}, nil
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, expected stdout, file:PATH or webhook:URL", spec)
+	}
+}
+
+// Stdout writes a human readable summary of every batch.
+type Stdout struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (n *Stdout) Notify(batch Batch) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, err := fmt.Fprintf(n.out, "[%s] %s: ", batch.Time.Format(time.RFC3339), batch.Target); err != nil {
+		return err
+	}
+	return diff.WriteSummary(n.out, batch.Events)
+}
+
+// File appends every event as ndjson, tagged with its target and time,
+// whatever the extension of Path.
+type File struct {
+	mu   sync.Mutex
+	Path string
+}
+
+func (n *File) Notify(batch Batch) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if err := fileutil.EnsureDir(filepath.Dir(n.Path)); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(n.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(file)
+	for _, line := range batch.Lines() {
+		if err := enc.Encode(line); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// Webhook sends the events of every batch as a single webhook request.
+type Webhook struct {
+	sender *webhook.Sender
+}
+
+func (n *Webhook) Notify(batch Batch) error {
+	return n.sender.Send(batch.Lines())
+}
+
+// Lines returns the events of the batch, each tagged with the target and time.
+func (b Batch) Lines() []any {
+	lines := make([]any, 0, len(b.Events))
+	for _, event := range b.Events {
+		lines = append(lines, struct {
+			Target string    `json:"target"`
+			Time   time.Time `json:"time"`
+			diff.Event
+		}{b.Target, b.Time, event})
+	}
+	return lines
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Doom-z/RepClient/pkg/diff"
+	"github.com/Doom-z/RepClient/pkg/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAppendsNDJSONWhateverTheExtension(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changes.log")
+	n, err := Parse("file:"+path, webhook.Config{})
+	require.NoError(t, err)
+
+	events := []diff.Event{
+		{Type: diff.Added, Key: map[string]string{"domain_id": "a.example.com"}},
+		{Type: diff.Removed, Key: map[string]string{"domain_id": "b.example.com"}},
+	}
+	require.NoError(t, n.Notify(NewBatch("1.1.1.1", events)))
+	require.NoError(t, n.Notify(NewBatch("2.2.2.2", events[:1])))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var line struct {
+			Target string         `json:"target"`
+			Type   diff.EventType `json:"type"`
+		}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		targets = append(targets, line.Target+" "+string(line.Type))
+	}
+	assert.Equal(t, []string{"1.1.1.1 added", "1.1.1.1 removed", "2.2.2.2 added"}, targets)
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"file:", "webhook", "email:me@example.com"} {
+		_, err := Parse(spec, webhook.Config{})
+		assert.Error(t, err, spec)
+	}
+}
@@ -0,0 +1,354 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	ttyInterval   = 500 * time.Millisecond
+	plainInterval = 10 * time.Second
+)
+
+// Tracker keeps track of how far a run is and periodically renders it.
+// When the output is a terminal the view is redrawn in place, and the log
+// lines of the console are written above it while it is shown. Otherwise a
+// plain log line is emitted every plainInterval.
+type Tracker struct {
+	mu          sync.Mutex
+	out         io.Writer
+	tty         bool
+	interval    time.Duration
+	start       time.Time
+	total       int
+	done        int
+	records     int64
+	errors      int
+	recordLimit int
+	concurrency func() int
+	workers     map[int]string
+
+	// drawMu guards the view on the terminal
+	drawMu     sync.Mutex
+	frame      []string
+	drawnLines int
+	restore    func()
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a tracker for total targets rendering to out, or only
+// logging the progress when out is nil. A total of 0 means the number of
+// targets is unknown.
+func New(total int, out *os.File) *Tracker {
+	tty := isTerminal(out)
+	interval := plainInterval
+	if tty {
+		interval = ttyInterval
+	}
+	var w io.Writer = io.Discard
+	if out != nil {
+		w = out
+	}
+	return &Tracker{
+		out:      w,
+		tty:      tty,
+		interval: interval,
+		total:    total,
+		workers:  map[int]string{},
+		stop:     make(chan struct{}),
+	}
+}
+
+// Disabled returns a tracker that counts but never renders anything.
+func Disabled() *Tracker {
+	return &Tracker{
+		out:     io.Discard,
+		workers: map[int]string{},
+		stop:    make(chan struct{}),
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// SetRecordLimit sets the expected record count for single target runs,
+// it is used to estimate the ETA when there is only one target.
+func (t *Tracker) SetRecordLimit(limit int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recordLimit = limit
+}
+
+// TrackConcurrency shows the number of workers of an adaptive run,
+// current is called on every render.
+func (t *Tracker) TrackConcurrency(current func() int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.concurrency = current
+}
+
+// Start begins rendering in the background until Stop is called.
+func (t *Tracker) Start() {
+	t.mu.Lock()
+	t.start = time.Now()
+	t.mu.Unlock()
+
+	if t.interval == 0 {
+		return
+	}
+	if t.tty {
+		t.restore = t.routeLogs()
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.render()
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops rendering and draws the final state once.
+func (t *Tracker) Stop() {
+	select {
+	case <-t.stop:
+		return
+	default:
+		close(t.stop)
+	}
+	t.wg.Wait()
+	if t.interval != 0 {
+		t.render()
+	}
+	if t.restore != nil {
+		t.restore()
+	}
+}
+
+// routeLogs makes the loggers writing to the console write through
+// logWriter, and returns the func restoring their outputs.
+func (t *Tracker) routeLogs() func() {
+	outputs := map[*logrus.Logger]io.Writer{}
+	logger.DefaultCombinedLogger.ApplyAll(func(l *logrus.Logger) {
+		if l.Out == os.Stdout || l.Out == os.Stderr {
+			outputs[l] = l.Out
+			l.SetOutput(&logWriter{t: t, out: l.Out})
+		}
+	})
+	return func() {
+		for l, out := range outputs {
+			l.SetOutput(out)
+		}
+	}
+}
+
+// logWriter writes log lines above the view: the view is cleared, the
+// line written and the view drawn again below it.
+type logWriter struct {
+	t   *Tracker
+	out io.Writer
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	t := w.t
+	t.drawMu.Lock()
+	defer t.drawMu.Unlock()
+
+	var b strings.Builder
+	t.clearFrame(&b)
+	_, _ = io.WriteString(t.out, b.String())
+	n, err := w.out.Write(p)
+	b.Reset()
+	t.drawFrame(&b)
+	_, _ = io.WriteString(t.out, b.String())
+	return n, err
+}
+
+// SetWorker records the target the given worker is currently working on.
+func (t *Tracker) SetWorker(workerID int, target string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.workers[workerID] = target
+}
+
+// TargetDone marks the current target of the given worker as finished.
+func (t *Tracker) TargetDone(workerID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.workers, workerID)
+	t.done++
+}
+
+// AddRecords adds n to the number of records received so far.
+func (t *Tracker) AddRecords(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records += int64(n)
+}
+
+// AddError increments the error counter.
+func (t *Tracker) AddError() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.errors++
+}
+
+// Snapshot is a point in time view of the tracker.
+type Snapshot struct {
+	Total      int
+	Done       int
+	Records    int64
+	Errors     int
+	Elapsed    time.Duration
+	RecordRate float64
+	ETA        time.Duration
+	// Concurrency is the limit of an adaptive run, 0 otherwise
+	Concurrency int
+	Workers     map[int]string
+}
+
+// Snapshot returns the current state of the tracker.
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	elapsed := time.Since(t.start)
+	s := Snapshot{
+		Total:   t.total,
+		Done:    t.done,
+		Records: t.records,
+		Errors:  t.errors,
+		Elapsed: elapsed,
+		ETA:     -1,
+		Workers: make(map[int]string, len(t.workers)),
+	}
+	if t.concurrency != nil {
+		s.Concurrency = t.concurrency()
+	}
+	for id, target := range t.workers {
+		s.Workers[id] = target
+	}
+	if secs := elapsed.Seconds(); secs > 0 {
+		s.RecordRate = float64(t.records) / secs
+	}
+
+	switch {
+	case t.total > 1 && t.done > 0 && t.done < t.total:
+		perTarget := elapsed / time.Duration(t.done)
+		s.ETA = perTarget * time.Duration(t.total-t.done)
+	case t.total <= 1 && t.recordLimit > 0 && t.records > 0 && t.records < int64(t.recordLimit):
+		perRecord := elapsed / time.Duration(t.records)
+		s.ETA = perRecord * time.Duration(int64(t.recordLimit)-t.records)
+	case t.total > 0 && t.done >= t.total:
+		s.ETA = 0
+	}
+	return s
+}
+
+func (t *Tracker) render() {
+	s := t.Snapshot()
+	if t.tty {
+		t.renderTTY(s)
+		return
+	}
+
+	fields := map[string]any{
+		"done":      s.Done,
+		"records":   s.Records,
+		"records/s": fmt.Sprintf("%.1f", s.RecordRate),
+		"errors":    s.Errors,
+		"elapsed":   s.Elapsed.Round(time.Second).String(),
+		"in_flight": len(s.Workers),
+		"eta":       formatETA(s.ETA),
+		"total":     s.Total,
+	}
+	if s.Concurrency > 0 {
+		fields["concurrency"] = s.Concurrency
+	}
+	logger.WithFields(fields).Info("Progress")
+}
+
+func (t *Tracker) renderTTY(s Snapshot) {
+	lines := []string{formatSummary(s)}
+	ids := make([]int, 0, len(s.Workers))
+	for id := range s.Workers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		lines = append(lines, fmt.Sprintf("  worker %d: %s", id, s.Workers[id]))
+	}
+
+	t.drawMu.Lock()
+	defer t.drawMu.Unlock()
+	t.frame = lines
+	var b strings.Builder
+	t.clearFrame(&b)
+	t.drawFrame(&b)
+	_, _ = io.WriteString(t.out, b.String())
+}
+
+// clearFrame moves the cursor back to the beginning of the drawn frame and
+// clears everything below it.
+func (t *Tracker) clearFrame(b *strings.Builder) {
+	if t.drawnLines > 0 {
+		fmt.Fprintf(b, "\033[%dA", t.drawnLines)
+	}
+	b.WriteString("\r\033[J")
+	t.drawnLines = 0
+}
+
+// drawFrame draws the last frame at the cursor.
+func (t *Tracker) drawFrame(b *strings.Builder) {
+	for _, line := range t.frame {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	t.drawnLines = len(t.frame)
+}
+
+func formatSummary(s Snapshot) string {
+	targets := fmt.Sprintf("%d", s.Done)
+	if s.Total > 0 {
+		targets = fmt.Sprintf("%d/%d (%.1f%%)", s.Done, s.Total, float64(s.Done)*100/float64(s.Total))
+	}
+	summary := fmt.Sprintf("targets %s | records %d (%.1f/s) | errors %d | elapsed %s | ETA %s",
+		targets, s.Records, s.RecordRate, s.Errors, s.Elapsed.Round(time.Second), formatETA(s.ETA))
+	if s.Concurrency > 0 {
+		summary += fmt.Sprintf(" | concurrency %d", s.Concurrency)
+	}
+	return summary
+}
+
+func formatETA(eta time.Duration) string {
+	if eta < 0 {
+		return "unknown"
+	}
+	return eta.Round(time.Second).String()
+}
@@ -0,0 +1,113 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// started returns a tracker for total targets started elapsed ago.
+func started(total int, elapsed time.Duration) *Tracker {
+	t := New(total, nil)
+	t.start = time.Now().Add(-elapsed)
+	return t
+}
+
+func TestSnapshotETA(t *testing.T) {
+	tests := []struct {
+		name        string
+		total, done int
+		records     int
+		recordLimit int
+		want        time.Duration
+	}{
+		{name: "by targets done", total: 4, done: 1, want: 30 * time.Second},
+		{name: "single target by records", total: 1, records: 25, recordLimit: 100, want: 30 * time.Second},
+		{name: "all targets done", total: 4, done: 4, want: 0},
+		{name: "unknown total", total: 0, done: 3, want: -1},
+		{name: "nothing done yet", total: 4, want: -1},
+		{name: "single target without limit", total: 1, records: 25, want: -1},
+		{name: "single target over its limit", total: 1, records: 120, recordLimit: 100, want: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := started(tt.total, 10*time.Second)
+			tr.done = tt.done
+			tr.AddRecords(tt.records)
+			tr.SetRecordLimit(tt.recordLimit)
+
+			eta := tr.Snapshot().ETA
+			if tt.want <= 0 {
+				assert.Equal(t, tt.want, eta)
+				return
+			}
+			assert.InDelta(t, tt.want.Seconds(), eta.Seconds(), 0.5)
+		})
+	}
+}
+
+func TestSnapshotRecordRate(t *testing.T) {
+	tr := started(2, 10*time.Second)
+	tr.AddRecords(40)
+	tr.AddRecords(10)
+	tr.AddError()
+
+	s := tr.Snapshot()
+	assert.InDelta(t, 5.0, s.RecordRate, 0.1)
+	assert.Equal(t, int64(50), s.Records)
+	assert.Equal(t, 1, s.Errors)
+}
+
+func TestNewWithoutTerminal(t *testing.T) {
+	tr := New(1, nil)
+	assert.False(t, tr.tty, "without a terminal the progress is logged")
+	assert.Equal(t, plainInterval, tr.interval)
+}
+
+func TestFormatETA(t *testing.T) {
+	assert.Equal(t, "unknown", formatETA(-1))
+	assert.Equal(t, "0s", formatETA(0))
+	assert.Equal(t, "1m30s", formatETA(90*time.Second+300*time.Millisecond))
+}
+
+func TestRenderTTYRedrawsInPlace(t *testing.T) {
+	var term bytes.Buffer
+	tr := started(2, 10*time.Second)
+	tr.out, tr.tty = &term, true
+
+	tr.SetWorker(1, "1.1.1.1")
+	tr.render()
+	tr.TargetDone(1)
+	tr.render()
+
+	frames := strings.Split(term.String(), "\r\033[J")
+	require.Len(t, frames, 3)
+	assert.Contains(t, frames[1], "worker 1: 1.1.1.1")
+	assert.True(t, strings.HasSuffix(frames[1], "\033[2A"), "the second frame starts at the first line of the first")
+	assert.NotContains(t, frames[2], "worker")
+	assert.Equal(t, 1, tr.drawnLines)
+}
+
+func TestLogWriterWritesAboveTheView(t *testing.T) {
+	var term, logs bytes.Buffer
+	tr := started(2, 10*time.Second)
+	tr.out, tr.tty = &term, true
+	tr.render()
+	term.Reset()
+
+	w := &logWriter{t: tr, out: &logs}
+	n, err := w.Write([]byte("level=info msg=found\n"))
+	require.NoError(t, err)
+	assert.Equal(t, len("level=info msg=found\n"), n)
+	assert.Equal(t, "level=info msg=found\n", logs.String())
+
+	cleared, redrawn, ok := strings.Cut(term.String(), "\r\033[J")
+	require.True(t, ok)
+	assert.Equal(t, "\033[1A", cleared, "the view is cleared before the log line")
+	assert.True(t, strings.HasPrefix(redrawn, "targets 0/2"), "and drawn again after it")
+	assert.Equal(t, 1, tr.drawnLines)
+}
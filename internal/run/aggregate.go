@@ -0,0 +1,54 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/stats"
+)
+
+// aggregateTop is the number of entries per dimension printed by --aggregate.
+const aggregateTop = 10
+
+// aggregateSink counts every emitted record and prints the report once
+// the run is done.
+type aggregateSink struct {
+	mu     sync.Mutex
+	agg    *stats.Aggregator
+	format string
+	out    reportOut
+}
+
+func newAggregateSink(format string, out reportOut) (*aggregateSink, error) {
+	if !slices.Contains(stats.Formats, format) {
+		return nil, fmt.Errorf("unsupported aggregate format %q, expected one of %s", format, strings.Join(stats.Formats, ", "))
+	}
+	agg, err := stats.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregateSink{agg: agg, format: format, out: out}, nil
+}
+
+func (s *aggregateSink) Write(record any) error {
+	m, err := fileutil.ToMap(record)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.agg.Add(m)
+	return nil
+}
+
+func (s *aggregateSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.out.write(func(w io.Writer) error {
+		return s.agg.Report(aggregateTop).Write(w, s.format)
+	})
+}
@@ -0,0 +1,113 @@
+package run
+
+import (
+	"context"
+	"time"
+
+	"github.com/Doom-z/RepClient/client"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Reasons a target is truncated for, see TargetSummary.Truncated.
+const (
+	TruncatedRecords  = "records"
+	TruncatedPages    = "pages"
+	TruncatedTime     = "time"
+	TruncatedDeadline = "deadline"
+)
+
+// pageFetcher starts the pages of a target at pageToken.
+type pageFetcher[T any] func(ctx context.Context, pageToken string) (<-chan client.Page[T], <-chan error)
+
+// streamPages feeds the records of target into fn until its pages end or
+// one of the budgets runs out: --max records, --max-pages, --target-timeout
+// or the --deadline of the run. A target stopped by a budget is recorded as
+// truncated with the page_token to resume it. It returns the number of
+// records, the budget that stopped the target, empty when its pages ended,
+// and the fetch error.
+func streamPages[T any](r *Run, target string, fetch pageFetcher[T], fn func(T)) (int, string, error) {
+	ctx, cancel := r.targetContext()
+	defer cancel()
+
+	maxRecords, maxPages := r.Args.MaxTotalOutputIp, r.Args.MaxPages
+	pagesCh, errCh := fetch(ctx, r.Args.PageToken)
+
+	count, pages, done := 0, 0, false
+	// next is the page_token of the page not read yet
+	next := r.Args.PageToken
+	reason, resume := "", ""
+read:
+	for page := range pagesCh {
+		pages++
+		for i, record := range page.Records {
+			if maxRecords > 0 && count >= maxRecords {
+				// fetching the page again repeats its first i records
+				reason, resume = TruncatedRecords, page.Token
+				logger.Debugf("%s: --max reached after %d of %d records of page %d", target, i, len(page.Records), page.Number)
+				break read
+			}
+			fn(record)
+			count++
+		}
+		next = page.Next
+		switch {
+		case next == "":
+			done = true
+		case maxRecords > 0 && count >= maxRecords:
+			reason, resume = TruncatedRecords, next
+		case maxPages > 0 && pages >= maxPages:
+			reason, resume = TruncatedPages, next
+		}
+		if reason != "" {
+			break
+		}
+	}
+	expired := ctx.Err() != nil
+	cancel()
+	err := <-errCh
+
+	if reason == "" && !done && expired {
+		reason, resume = TruncatedTime, next
+		if r.ctx.Err() != nil {
+			reason = TruncatedDeadline
+		}
+	}
+	if reason != "" {
+		r.truncated(target, reason, resume)
+	}
+	return count, reason, err
+}
+
+// targetContext returns the context of a target, ending with the run
+// deadline or after --target-timeout.
+func (r *Run) targetContext() (context.Context, context.CancelFunc) {
+	if r.Args.TargetTimeout > 0 {
+		return context.WithTimeout(r.ctx, r.Args.TargetTimeout)
+	}
+	return context.WithCancel(r.ctx)
+}
+
+// runContext returns the context of the run, ending with parent or after
+// --deadline.
+func (r *Run) runContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if r.Args.Deadline > 0 {
+		logger.Infof("Run deadline at %s", time.Now().Add(r.Args.Deadline).Format(time.RFC3339))
+		return context.WithTimeout(parent, r.Args.Deadline)
+	}
+	return context.WithCancel(parent)
+}
+
+// truncated records target as stopped by the budget reason. Reaching --max
+// is the expected end of most targets and only logged at info level.
+func (r *Run) truncated(target, reason, pageToken string) {
+	log := logger.Warnf
+	if reason == TruncatedRecords {
+		log = logger.Infof
+	}
+	if pageToken != "" {
+		log("%s truncated by the %s budget, resume with --page-token %s", target, reason, pageToken)
+	} else {
+		log("%s truncated by the %s budget", target, reason)
+	}
+	r.Report.Truncate(target, reason, pageToken)
+}
@@ -0,0 +1,80 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Doom-z/RepClient/client"
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamPagesBudgets(t *testing.T) {
+	api := &pagingAPI{pages: 3}
+	srv := api.start(t)
+
+	tests := []struct {
+		name      string
+		opts      args.Options
+		count     int
+		reason    string
+		pageToken string
+	}{
+		{"all pages", args.Options{}, 6, "", ""},
+		{"max within a page", args.Options{PagingFlags: args.PagingFlags{MaxTotalOutputIp: 3}}, 3, TruncatedRecords, "1"},
+		{"max at a page end", args.Options{PagingFlags: args.PagingFlags{MaxTotalOutputIp: 4}}, 4, TruncatedRecords, "2"},
+		{"max pages", args.Options{BudgetFlags: args.BudgetFlags{MaxPages: 2}}, 4, TruncatedPages, "2"},
+		{"resumed", args.Options{PageToken: "1"}, 4, "", ""},
+		{"resumed with max pages", args.Options{PageToken: "1", BudgetFlags: args.BudgetFlags{MaxPages: 1}}, 2, TruncatedPages, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRun(t, srv.URL, tt.opts)
+			count, reason, err := streamTarget(r, "1.1.1.1")
+			require.NoError(t, err)
+			assert.Equal(t, tt.count, count)
+			assert.Equal(t, tt.reason, reason)
+
+			target := r.Report.Summary(cfg.Conf{}, r.Args).Targets
+			if tt.reason == "" {
+				assert.Empty(t, target)
+				return
+			}
+			require.Len(t, target, 1)
+			assert.Equal(t, tt.reason, target[0].Truncated)
+			assert.Equal(t, tt.pageToken, target[0].PageToken)
+		})
+	}
+}
+
+func TestStreamPagesTimeBudgets(t *testing.T) {
+	api := &pagingAPI{pages: 3, stall: true}
+	srv := api.start(t)
+
+	r := newTestRun(t, srv.URL, args.Options{BudgetFlags: args.BudgetFlags{TargetTimeout: 50 * time.Millisecond}})
+	count, reason, _ := streamTarget(r, "1.1.1.1")
+	assert.Equal(t, 2, count)
+	assert.Equal(t, TruncatedTime, reason)
+	assert.Equal(t, "1", r.Report.Summary(cfg.Conf{}, r.Args).Targets[0].PageToken)
+
+	r = newTestRun(t, srv.URL, args.Options{BudgetFlags: args.BudgetFlags{Deadline: 50 * time.Millisecond}})
+	ctx, cancel := r.runContext(context.Background())
+	defer cancel()
+	r.ctx = ctx
+	count, reason, _ = streamTarget(r, "1.1.1.1")
+	assert.Equal(t, 2, count)
+	assert.Equal(t, TruncatedDeadline, reason)
+	assert.Equal(t, "1", r.Report.Summary(cfg.Conf{}, r.Args).Targets[0].PageToken)
+}
+
+// streamTarget streams the records of the ip target through streamPages.
+func streamTarget(r *Run, target string) (int, string, error) {
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[model.Record], <-chan error) {
+		return r.Client.FetchRecordPages(ctx, "ip", target, pageToken)
+	}
+	return streamPages(r, target, fetch, func(model.Record) {})
+}
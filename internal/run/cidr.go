@@ -0,0 +1,41 @@
+package run
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/Doom-z/RepClient/pkg/cidr"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+)
+
+// cidrSink collects the IPs of every emitted record and prints them
+// collapsed into prefixes once the run is done.
+type cidrSink struct {
+	set    *cidr.Set
+	format string
+	byASN  bool
+	out    reportOut
+}
+
+func newCidrSink(format string, byASN bool, out reportOut) (*cidrSink, error) {
+	if !slices.Contains(cidr.Formats, format) {
+		return nil, fmt.Errorf("unsupported cidr format %q, expected one of %s", format, strings.Join(cidr.Formats, ", "))
+	}
+	return &cidrSink{set: cidr.NewSet(), format: format, byASN: byASN, out: out}, nil
+}
+
+func (s *cidrSink) Write(record any) error {
+	m, err := fileutil.ToMap(record)
+	if err != nil {
+		return err
+	}
+	return s.set.AddRecord(m)
+}
+
+func (s *cidrSink) Close() error {
+	return s.out.write(func(w io.Writer) error {
+		return cidr.Write(w, s.set.Prefixes(s.byASN), s.format)
+	})
+}
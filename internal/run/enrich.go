@@ -0,0 +1,34 @@
+package run
+
+import (
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/enrich"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// newEnricher opens the enrichment databases of the config, it returns
+// nil when none is configured.
+func newEnricher(conf cfg.Conf) (*enrich.Enricher, error) {
+	return enrich.New(enrich.Config{
+		MMDB:   conf.Enrich.MMDB,
+		IP2ASN: conf.Enrich.IP2ASN,
+	})
+}
+
+// enrich adds the ASN and geo fields of the local databases to a
+// lightweight record. Full mode records already carry them and records
+// that were enriched before are returned as is.
+func (r *Run) enrich(record model.Record) model.Record {
+	if r.Enricher == nil || record.IP == "" || record.ASN != 0 || record.Country != "" {
+		return record
+	}
+	info, ok, err := r.Enricher.Lookup(record.IP)
+	if err != nil {
+		logger.Debugf("enrichment of %s failed: %v", record.IP, err)
+	}
+	if ok {
+		record.ASN, record.ASNName, record.Country, record.City = info.ASN, info.ASNName, info.Country, info.City
+	}
+	return record
+}
@@ -5,33 +5,40 @@ import (
 	"sync"
 
 	"github.com/Doom-z/RepClient/client/model"
-	"github.com/Doom-z/RepClient/pkg/fileutil"
 	"github.com/Doom-z/RepClient/pkg/logger"
 )
 
 func (r *Run) fetchAndSaveRecords(param, target string) {
 	logger.Tracef("Fetching (%s) records for %s with max records: %d", param, target, r.Args.MaxTotalOutputIp)
+	r.Report.Begin(param, target)
 
 	records, err := r.Client.FetchRecords(param, target)
 	if err != nil {
 		logger.Warnf("Client fetch error: %v", err)
+		r.targetError(target, err)
 	}
 	outputPath := fmt.Sprintf("%s/stream.%s", r.Cfg.Output.Dir, r.Cfg.Output.Format)
 
 	if len(records) > 0 {
 		for _, record := range records {
-			if r.Args.Output {
-				if r.Cfg.Output.Format == "txt" {
-					fileutil.SaveData(record.DomainID, outputPath, "append")
-				} else {
-					fileutil.SaveData(record, outputPath, "append")
-				}
-			} else {
-				logger.WithFields(map[string]any{
+			record = r.enrich(record)
+			r.recordFound(target, record.RecordType)
+			if r.emitting() {
+				r.emit(SaveTask{Data: record, Path: outputPath, Format: r.Cfg.Output.Format})
+			}
+			if !r.Args.Output {
+				fields := map[string]any{
 					"domain": record.DomainID,
 					"type":   record.RecordType,
 					"ip":     record.IP,
-				}).Infof("Found record")
+				}
+				if record.ASN != 0 {
+					fields["asn"], fields["asn_name"] = record.ASN, record.ASNName
+				}
+				if record.Country != "" {
+					fields["country"], fields["city"] = record.Country, record.City
+				}
+				logger.WithFields(r.timeFields(fields, record.Timestamp)).Infof("Found record")
 			}
 		}
 	}
@@ -48,10 +55,12 @@ func (r *Run) fetchARecordStream(ipv4 string) {
 	saveTasks := make(chan SaveTask, 100)
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go startSaveWorker(&wg, saveTasks)
+	go r.startSaveWorker(&wg, saveTasks)
 
-	processTypedStream(r.Client, "a", ipv4, func(record model.ARecord) {
-		logger.WithFields(map[string]any{
+	r.Report.Begin("a", ipv4)
+	err := processTypedStream(r, "a", ipv4, func(record model.ARecord) {
+		r.recordFound(ipv4, "a")
+		logger.WithFields(r.timeFields(map[string]any{
 			"domain":   record.DomainID,
 			"ip":       record.IP,
 			"asn":      record.ASN,
@@ -59,8 +68,12 @@ func (r *Run) fetchARecordStream(ipv4 string) {
 			"country":  record.Country,
 			"city":     record.City,
 			"latlong":  record.LatLong,
-		}).Info("A Record found")
-	}, saveTasks, outputPath, r.Cfg.Output.Format, r.Args.Output)
+		}, record.Timestamp)).Info("A Record found")
+	}, saveTasks, outputPath, r.Cfg.Output.Format, r.emitting())
+	if err != nil {
+		logger.Warnf("Client fetch error: %v", err)
+		r.targetError(ipv4, err)
+	}
 
 	close(saveTasks)
 	wg.Wait()
@@ -71,10 +84,12 @@ func (r *Run) fetchAAAARecordStream(ipv6 string) {
 	saveTasks := make(chan SaveTask, 100)
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go startSaveWorker(&wg, saveTasks)
+	go r.startSaveWorker(&wg, saveTasks)
 
-	processTypedStream(r.Client, "aaaa", ipv6, func(record model.AAAARecord) {
-		logger.WithFields(map[string]any{
+	r.Report.Begin("aaaa", ipv6)
+	err := processTypedStream(r, "aaaa", ipv6, func(record model.AAAARecord) {
+		r.recordFound(ipv6, "aaaa")
+		logger.WithFields(r.timeFields(map[string]any{
 			"domain":   record.DomainID,
 			"ip":       record.IP,
 			"asn":      record.ASN,
@@ -82,8 +97,12 @@ func (r *Run) fetchAAAARecordStream(ipv6 string) {
 			"country":  record.Country,
 			"city":     record.City,
 			"latlong":  record.LatLong,
-		}).Info("AAAA Record found")
-	}, saveTasks, outputPath, r.Cfg.Output.Format, r.Args.Output)
+		}, record.Timestamp)).Info("AAAA Record found")
+	}, saveTasks, outputPath, r.Cfg.Output.Format, r.emitting())
+	if err != nil {
+		logger.Warnf("Client fetch error: %v", err)
+		r.targetError(ipv6, err)
+	}
 
 	close(saveTasks)
 	wg.Wait()
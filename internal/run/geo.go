@@ -0,0 +1,95 @@
+package run
+
+import (
+	"os"
+	"sync"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/pkg/geojson"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// geoOutput accumulates the locations of the geojson output files, which
+// can only be written once every record is known.
+type geoOutput struct {
+	mu      sync.Mutex
+	files   map[string]*geojson.Collection
+	order   []string
+	skipped int
+}
+
+func newGeoOutput() *geoOutput {
+	return &geoOutput{files: map[string]*geojson.Collection{}}
+}
+
+// addLocation adds the location of record to the geojson file at path.
+// Records without a valid LatLong, such as lightweight ones, are counted
+// and skipped.
+func (r *Run) addLocation(record any, path string) {
+	var point geojson.Point
+	var latlong string
+	switch rec := record.(type) {
+	case model.ARecord:
+		latlong = rec.LatLong
+		point = geojson.Point{Domain: rec.DomainID, City: rec.City, Country: rec.Country, ASN: rec.ASN, ASNName: rec.ASNName}
+	case model.AAAARecord:
+		latlong = rec.LatLong
+		point = geojson.Point{Domain: rec.DomainID, City: rec.City, Country: rec.Country, ASN: rec.ASN, ASNName: rec.ASNName}
+	}
+
+	g := r.geo
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	location, err := model.ParseLatLong(latlong)
+	if err != nil {
+		g.skipped++
+		logger.Debugf("skipping location: %v", err)
+		return
+	}
+	point.Lat, point.Long = location.Lat, location.Long
+
+	c, ok := g.files[path]
+	if !ok {
+		c = geojson.New()
+		g.files[path] = c
+		g.order = append(g.order, path)
+	}
+	c.Add(point)
+}
+
+// writeGeoJSON writes every accumulated geojson file.
+func (r *Run) writeGeoJSON() {
+	g := r.geo
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.skipped > 0 {
+		logger.Warnf("%d records without a valid location were skipped in the geojson output", g.skipped)
+		r.Report.AddSkippedLocations(g.skipped)
+	}
+	for _, path := range g.order {
+		c := g.files[path]
+		if err := writeGeoJSONFile(c, path); err != nil {
+			logger.Warnf("failed to write %s: %v", path, err)
+			r.Report.AddSaveError()
+			continue
+		}
+		r.Report.AddOutputFile(path)
+		logger.WithFields(map[string]any{
+			"locations": c.Len(),
+			"path":      path,
+		}).Info("GeoJSON written")
+	}
+}
+
+func writeGeoJSONFile(c *geojson.Collection, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
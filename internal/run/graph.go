@@ -0,0 +1,89 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/graph"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// graphSink builds a relationship graph from every emitted record and
+// writes it once the run is done.
+type graphSink struct {
+	graph  *graph.Graph
+	path   string
+	format string
+}
+
+// newGraphSink creates a sink writing to path, in the format matching its extension.
+func newGraphSink(path string) (*graphSink, error) {
+	format := graph.FormatFromPath(path)
+	if format == "" {
+		return nil, fmt.Errorf("unsupported graph file %q, expected a .%s extension", path, strings.Join(graph.Formats, ", ."))
+	}
+	return &graphSink{graph: graph.New(), path: path, format: format}, nil
+}
+
+func (s *graphSink) Write(record any) error {
+	if rec, ok := graphRecord(record); ok {
+		s.graph.AddRecord(rec)
+	}
+	return nil
+}
+
+func (s *graphSink) Close() error {
+	return writeGraphFile(s.graph, s.path, s.format)
+}
+
+// graphRecord converts the client records to a graph record.
+func graphRecord(record any) (graph.Record, bool) {
+	switch r := record.(type) {
+	case model.Record:
+		return graph.Record{DomainID: r.DomainID, IP: r.IP, RecordType: strings.ToUpper(r.RecordType), Timestamp: r.Timestamp, ASN: r.ASN, ASNName: r.ASNName, Country: r.Country}, true
+	case model.ARecord:
+		return graph.Record{DomainID: r.DomainID, IP: r.IP, RecordType: "A", Timestamp: r.Timestamp, ASN: r.ASN, ASNName: r.ASNName, Country: r.Country}, true
+	case model.AAAARecord:
+		return graph.Record{DomainID: r.DomainID, IP: r.IP, RecordType: "AAAA", Timestamp: r.Timestamp, ASN: r.ASN, ASNName: r.ASNName, Country: r.Country}, true
+	default:
+		return graph.Record{}, false
+	}
+}
+
+// graphPath returns the graph file of the run, --graph overrides
+// output.graph and relative paths are placed inside the output dir.
+func graphPath(path, dir string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+func writeGraphFile(g *graph.Graph, path, format string) error {
+	if err := fileutil.EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logger.WithFields(map[string]any{
+		"nodes":  len(g.Nodes()),
+		"edges":  len(g.Edges()),
+		"format": format,
+		"path":   path,
+	}).Info("Graph written")
+	return nil
+}
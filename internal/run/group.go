@@ -0,0 +1,44 @@
+package run
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+)
+
+// groupNone is the group of records without a value for the --group-by field.
+const groupNone = "_none"
+
+// groupedPath returns the output path of record when --group-by is set:
+// the file is placed in a directory named after the value of the field,
+// e.g. out/example.co.uk/stream.ndjson.
+func (r *Run) groupedPath(record any, path string) (string, error) {
+	m, err := fileutil.ToMap(record)
+	if err != nil {
+		return "", err
+	}
+	group := groupNone
+	if value, ok := m[r.Args.GroupBy]; ok && value != nil && fmt.Sprint(value) != "" {
+		group = groupDirName(fmt.Sprint(value))
+	}
+
+	dir := filepath.Join(filepath.Dir(path), group)
+	if err := fileutil.EnsureDir(dir); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
+// groupDirName replaces the characters that are not safe in a directory name.
+func groupDirName(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, value)
+}
@@ -0,0 +1,223 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/internal/progress"
+	"github.com/Doom-z/RepClient/pkg/graph"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// DefaultPivotTypes are the query parameters used to expand domain nodes.
+var DefaultPivotTypes = []string{"ip", "ns", "mx", "cname"}
+
+type pivotNode struct {
+	id    string
+	kind  graph.Kind
+	label string
+}
+
+// Pivot crawls outwards from the seed targets. Every IP is expanded to the
+// domains pointing at it and every domain to the records of the pivot types,
+// for up to cmd.Depth hops and cmd.MaxNodes expanded nodes. The resulting
+// graph links every visited node by record type. The trial API has no
+// paging, so pivot needs a paid key.
+func (r *Run) Pivot(cmd args.PivotCmd) ExitStatus {
+	if r.Args.Trial {
+		logger.Fatal("Pivot pages through the API and only works in paid plans")
+	}
+	seeds := append([]string{}, cmd.Seeds...)
+	if r.Args.ListFile != "" {
+		for line := range StreamFile(r.Args.ListFile) {
+			if trimmed := strings.TrimSpace(line); trimmed != "" {
+				seeds = append(seeds, trimmed)
+			}
+		}
+	}
+	if len(seeds) == 0 {
+		logger.Fatal("You must provide at least one seed target or --list-file")
+	}
+	if cmd.Format != "" && !slices.Contains(graph.Formats, cmd.Format) {
+		logger.Fatalf("Unsupported graph format %q, expected one of %s", cmd.Format, strings.Join(graph.Formats, ", "))
+	}
+
+	if !r.Args.NoProgress {
+		r.Progress = progress.New(0, os.Stderr)
+	}
+	r.Progress.Start()
+	ctx, cancel := r.runContext(context.Background())
+	defer cancel()
+	r.ctx = ctx
+
+	g := graph.New()
+	var frontier []pivotNode
+	for _, seed := range seeds {
+		node := newPivotNode(g, seed)
+		g.SetAttr(node.id, "depth", "0")
+		frontier = append(frontier, node)
+	}
+
+	expanded := 0
+	for depth := 0; depth < cmd.Depth && len(frontier) > 0; depth++ {
+		if left := cmd.MaxNodes - expanded; cmd.MaxNodes > 0 && len(frontier) > left {
+			logger.Warnf("Node budget of %d reached, %d nodes at depth %d are not expanded", cmd.MaxNodes, len(frontier)-left, depth)
+			frontier = frontier[:left]
+		}
+		if len(frontier) == 0 {
+			break
+		}
+		if r.ctx.Err() != nil {
+			logger.Warnf("Run deadline reached, %d nodes at depth %d are not expanded", len(frontier), depth)
+			for _, node := range frontier {
+				r.Report.Truncate(node.label, TruncatedDeadline, "")
+			}
+			break
+		}
+
+		logger.Infof("Pivot depth %d: expanding %d nodes", depth, len(frontier))
+		expanded += len(frontier)
+		frontier = r.expandLevel(g, frontier, depth+1, cmd)
+	}
+
+	if err := r.writeGraph(g, cmd); err != nil {
+		logger.Warnf("failed to write pivot graph: %v", err)
+		r.Report.AddSaveError()
+	}
+
+	r.closeSinks()
+	r.Progress.Stop()
+	return r.writeSummary()
+}
+
+func newPivotNode(g *graph.Graph, target string) pivotNode {
+	kind := graph.KindDomain
+	if net.ParseIP(target) != nil {
+		kind = graph.KindIP
+	}
+	id, _ := g.AddNode(kind, target)
+	return pivotNode{id: id, kind: kind, label: target}
+}
+
+// expandLevel expands every node of frontier with the worker pool and
+// returns the nodes discovered for the first time, at depth.
+func (r *Run) expandLevel(g *graph.Graph, frontier []pivotNode, depth int, cmd args.PivotCmd) []pivotNode {
+	jobs := make(chan pivotNode, r.Args.Threads*2)
+	var next []pivotNode
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.Args.Threads; i++ {
+		wg.Add(1)
+		go func(workerID int) {
+			defer wg.Done()
+			for node := range jobs {
+				r.trackTarget(workerID, node.label, func() {
+					found := r.expandNode(g, node, depth, cmd)
+					mu.Lock()
+					next = append(next, found...)
+					mu.Unlock()
+				})
+			}
+		}(i)
+	}
+
+	for _, node := range frontier {
+		jobs <- node
+	}
+	close(jobs)
+	wg.Wait()
+	return next
+}
+
+// expandNode queries node and links every record into g. It returns the
+// nodes that were not in the graph before.
+func (r *Run) expandNode(g *graph.Graph, node pivotNode, depth int, cmd args.PivotCmd) []pivotNode {
+	params := cmd.Types
+	if len(params) == 0 {
+		params = DefaultPivotTypes
+	}
+	if node.kind == graph.KindIP {
+		params = []string{"ip"}
+		if ip := net.ParseIP(node.label); ip.To4() == nil {
+			// the paging endpoint only takes IPv4 addresses
+			params = []string{"aaaa"}
+		}
+	}
+	outputPath := fmt.Sprintf("%s/pivot.%s", r.Cfg.Output.Dir, r.Cfg.Output.Format)
+
+	var found []pivotNode
+	for _, param := range params {
+		r.Report.Begin(param, node.label)
+		if r.ctx.Err() != nil {
+			// the run deadline passed before the node was expanded
+			r.Report.Truncate(node.label, TruncatedDeadline, "")
+			continue
+		}
+		fetch := func(fn func(model.Record)) (string, error) {
+			return r.fetchRecords(param, node.label, fn)
+		}
+		if param == "aaaa" {
+			fetch = func(fn func(model.Record)) (string, error) {
+				return r.fetchAAAARecords(node.label, fn)
+			}
+		}
+		_, err := fetch(func(record model.Record) {
+			r.recordFound(node.label, record.RecordType)
+			if r.emitting() {
+				r.emit(SaveTask{Data: record, Path: outputPath, Format: r.Cfg.Output.Format})
+			}
+			rec, _ := graphRecord(record)
+			for _, added := range g.AddRecord(rec) {
+				g.SetAttr(added.ID, "depth", strconv.Itoa(depth))
+				if added.Kind == graph.KindIP || added.Kind == graph.KindDomain {
+					found = append(found, pivotNode{id: added.ID, kind: added.Kind, label: added.Label})
+				}
+			}
+
+			// e.g. the ns query of a domain returns records of its name servers
+			domainID := graph.NodeID(graph.KindDomain, record.DomainID)
+			ipID := graph.NodeID(graph.KindIP, record.IP)
+			if record.DomainID != "" && node.id != domainID && node.id != ipID {
+				g.AddEdge(node.id, domainID, strings.ToUpper(param), record.Timestamp)
+			}
+		})
+		if err != nil {
+			logger.Warnf("Client fetch error for %s (%s): %v", node.label, param, err)
+			r.targetError(node.label, err)
+		}
+	}
+	g.SetAttr(node.id, "expanded", "true")
+	return found
+}
+
+// writeGraph writes g to cmd.Out, by default pivot.<format> inside the
+// output dir. The format is cmd.Format, else the extension of cmd.Out.
+func (r *Run) writeGraph(g *graph.Graph, cmd args.PivotCmd) error {
+	format := cmd.Format
+	if format == "" {
+		format = graph.FormatFromPath(cmd.Out)
+	}
+	if format == "" {
+		format = "json"
+	}
+	path := cmd.Out
+	if path == "" {
+		path = filepath.Join(r.Cfg.Output.Dir, "pivot."+format)
+	}
+
+	if err := writeGraphFile(g, path, format); err != nil {
+		return err
+	}
+	r.Report.AddOutputFile(path)
+	return nil
+}
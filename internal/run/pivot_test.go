@@ -0,0 +1,79 @@
+package run
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPivotDepthAndMaxNodes(t *testing.T) {
+	api := &pagingAPI{pages: 1}
+	srv := api.start(t)
+
+	// 1.1.1.1 points at d1.1.1.1.1 and d2.1.1.1.1, whose name servers are
+	// 10.0.0.1 and 10.0.0.2
+	tests := []struct {
+		name     string
+		depth    int
+		maxNodes int
+		expanded []string
+		nodes    int
+	}{
+		{"one hop", 1, 0, []string{"1.1.1.1"}, 3},
+		{"two hops", 2, 0, []string{"1.1.1.1", "d1.1.1.1.1", "d2.1.1.1.1"}, 5},
+		{"three hops", 3, 0, []string{"1.1.1.1", "d1.1.1.1.1", "d2.1.1.1.1", "10.0.0.1", "10.0.0.2"}, 9},
+		{"node budget", 3, 2, []string{"1.1.1.1", "d1.1.1.1.1"}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRun(t, srv.URL, args.Options{})
+			out := filepath.Join(t.TempDir(), "pivot.json")
+			status := r.Pivot(args.PivotCmd{Seeds: []string{"1.1.1.1"}, Depth: tt.depth, MaxNodes: tt.maxNodes, Types: []string{"ns"}, Out: out})
+			assert.Equal(t, ExitSuccess, status)
+
+			var expanded []string
+			for _, target := range r.Report.Summary(cfg.Conf{}, r.Args).Targets {
+				expanded = append(expanded, target.Target)
+			}
+			assert.ElementsMatch(t, tt.expanded, expanded)
+			assert.Len(t, readGraph(t, out).Nodes, tt.nodes)
+		})
+	}
+}
+
+func TestPivotExpandsIPv6ThroughTheAAAAEndpoint(t *testing.T) {
+	api := &pagingAPI{pages: 1}
+	srv := api.start(t)
+
+	r := newTestRun(t, srv.URL, args.Options{})
+	out := filepath.Join(t.TempDir(), "pivot.json")
+	assert.Equal(t, ExitSuccess, r.Pivot(args.PivotCmd{Seeds: []string{"2001:db8::1"}, Depth: 1, Out: out}))
+
+	targets := r.Report.Summary(cfg.Conf{}, r.Args).Targets
+	require.Len(t, targets, 1)
+	assert.Equal(t, "aaaa", targets[0].Param)
+	assert.Equal(t, map[string]int{"AAAA": 2}, targets[0].Totals)
+
+	var labels []string
+	for _, node := range readGraph(t, out).Nodes {
+		labels = append(labels, node.Label)
+	}
+	assert.ElementsMatch(t, []string{"2001:db8::1", "d1.v6.test", "d2.v6.test"}, labels)
+}
+
+func readGraph(t *testing.T, path string) (g struct {
+	Nodes []graph.Node `json:"nodes"`
+	Edges []graph.Edge `json:"edges"`
+}) {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &g))
+	return g
+}
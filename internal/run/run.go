@@ -1,29 +1,144 @@
 package run
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Doom-z/RepClient/client"
 	"github.com/Doom-z/RepClient/cmd/app/args"
 	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/internal/progress"
+	"github.com/Doom-z/RepClient/pkg/aimd"
+	"github.com/Doom-z/RepClient/pkg/enrich"
 	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/linetmpl"
 	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/timefmt"
+	"github.com/Doom-z/RepClient/pkg/usage"
+	"github.com/Doom-z/RepClient/pkg/utils"
 )
 
 type Run struct {
-	Client *client.Client
-	Args   args.Args
-	Cfg    cfg.Conf
+	Client   *client.Client
+	Args     args.Options
+	Cfg      cfg.Conf
+	Progress *progress.Tracker
+	Report   *Report
+	Sinks    []Sink
+	Enricher *enrich.Enricher
+
+	geo      *geoOutput
+	timeMode timefmt.Mode
+	lineTmpl *linetmpl.Template
+	keyPool  *client.KeyPool
+	meter    *usage.Meter
+	// concurrency adapts the active workers of list runs, nil for --threads
+	concurrency *aimd.Controller
+	// ctx ends at the --deadline of the run
+	ctx context.Context
 }
 
-func NewRun(args args.Args, cfg cfg.Conf) (*Run, error) {
-	c, err := client.NewClient(
-		cfg.Api.Host,
+func NewRun(args args.Options, cfg cfg.Conf) (*Run, error) {
+	window, err := timeWindow(args)
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := cfg.Output.Timestamp
+	if args.Timestamp != "" {
+		timestamp = args.Timestamp
+	}
+	timeMode, err := timefmt.ParseMode(timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	if args.Template != "" {
+		cfg.Output.Format = "template"
+		cfg.Output.Template = args.Template
+	}
+	var lineTmpl *linetmpl.Template
+	if cfg.Output.Format == "template" {
+		if lineTmpl, err = linetmpl.Parse(cfg.Output.Template); err != nil {
+			return nil, fmt.Errorf("output template: %w", err)
+		}
+	}
+
+	if cfg.Profile != "" {
+		logger.WithFields(map[string]any{
+			"profile": cfg.Profile,
+			"host":    cfg.Api.Host,
+		}).Info("Using config profile")
+	}
+
+	httpClient, err := client.NewHTTPClient(transportConfig(cfg.Api.Transport))
+	if err != nil {
+		return nil, fmt.Errorf("api transport: %w", err)
+	}
+	opts := []client.Option{
+		client.WithHTTPClient(httpClient),
 		client.WithPageSize(args.PageSize),
 		client.WithApiKey(cfg.Api.Apikey),
-	)
+		client.WithRateLimit(cfg.Api.RateLimit, cfg.Api.Burst),
+		client.WithTimeWindow(window),
+	}
+	if len(cfg.Api.Hosts) > 0 {
+		hosts := make([]client.Host, len(cfg.Api.Hosts))
+		for i, host := range cfg.Api.Hosts {
+			hosts[i] = client.Host{URL: host.URL, Priority: host.Priority}
+		}
+		opts = append(opts, client.WithFailover(client.Failover{
+			Hosts:          hosts,
+			MaxFailures:    cfg.Api.HostMaxFailures,
+			CoolDown:       cfg.Api.HostCoolDown,
+			PortableTokens: cfg.Api.PortablePageTokens,
+		}))
+	}
+	var keyPool *client.KeyPool
+	if len(cfg.Api.Keys) > 0 {
+		keys := make([]client.Key, len(cfg.Api.Keys))
+		for i, key := range cfg.Api.Keys {
+			keys[i] = client.Key{Key: key.Key, Weight: key.Weight, DailyBudget: key.DailyBudget}
+		}
+		if keyPool, err = client.NewKeyPool(keys, cfg.Api.KeyUsage); err != nil {
+			return nil, fmt.Errorf("api key pool init error: %w", err)
+		}
+		opts = append(opts, client.WithKeyPool(keyPool))
+	}
+
+	var ledger []usage.Entry
+	if cfg.Usage.Ledger != "" {
+		if ledger, err = (usage.Ledger{Path: cfg.Usage.Ledger}).Load(); err != nil {
+			return nil, err
+		}
+	}
+	limits := usage.Limits{
+		MaxRequestsPerDay:  cfg.Usage.MaxRequestsPerDay,
+		SoftRequestsPerDay: cfg.Usage.SoftRequestsPerDay,
+	}
+	if cfg.Trial() {
+		limits.TrialRecords = usage.TrialRecords
+	}
+	meter := usage.NewMeter(limits, ledger)
+	opts = append(opts, client.WithUsage(meter))
+
+	var concurrency *aimd.Controller
+	if args.Adaptive && args.ListFile != "" {
+		concurrency = aimd.New(aimd.Config{Min: args.MinThreads, Max: args.MaxThreads, Initial: args.Threads})
+		opts = append(opts, client.WithObserver(func(latency time.Duration, err error) {
+			var status *client.StatusError
+			throttled := errors.As(err, &status) && status.RateLimited()
+			failed := err != nil && !throttled && !errors.Is(err, context.Canceled) && (status == nil || status.Code >= 500)
+			concurrency.Observe(latency, failed, throttled)
+		}))
+	}
+
+	c, err := client.NewClient(cfg.Api.Host, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("client init error: %w", err)
 	}
@@ -34,98 +149,180 @@ func NewRun(args args.Args, cfg cfg.Conf) (*Run, error) {
 		}
 	}
 
-	return &Run{
-		Client: c,
-		Args:   args,
-		Cfg:    cfg,
-	}, nil
-}
-
-func (r *Run) Start() {
-	args := r.Args
+	sinks, err := newSinks(args, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("output sink init error: %w", err)
+	}
 
-	switch {
-	case args.Trial && args.ListFile == "":
-		r.runTrialSingleIP()
-	case args.Trial && args.ListFile != "":
-		r.runTrialFromFile() // a.k.a bulk scan from file
+	enricher, err := newEnricher(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment init error: %w", err)
+	}
 
-	case args.Ipv6 != "" && args.ModeFull:
-		r.runFullIPv6Scan(args.Ipv6)
-	case args.Ipv4 != "" && args.ModeFull:
-		r.runFullIPv4Scan(args.Ipv4)
+	return &Run{
+		Client:   c,
+		Args:     args,
+		Cfg:      cfg,
+		Progress: progress.Disabled(),
+		Report:   NewReport(),
+		Sinks:    sinks,
+		Enricher: enricher,
+		geo:      newGeoOutput(),
+		timeMode: timeMode,
+		lineTmpl: lineTmpl,
+		keyPool:  keyPool,
+		meter:    meter,
 
-	case args.ListFile != "" && !args.Trial:
-		r.runBulkScanFromFile()
+		concurrency: concurrency,
+		ctx:         context.Background(),
+	}, nil
+}
 
-	default:
-		r.runSingleIPScan()
+func transportConfig(t cfg.Transport) client.TransportConfig {
+	return client.TransportConfig{
+		Proxy:                 t.Proxy,
+		CABundle:              t.CABundle,
+		CertFile:              t.CertFile,
+		KeyFile:               t.KeyFile,
+		MinTLSVersion:         t.MinTLSVersion,
+		Timeout:               t.Timeout,
+		DialTimeout:           t.DialTimeout,
+		TLSHandshakeTimeout:   t.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: t.ResponseHeaderTimeout,
+		IdleConnTimeout:       t.IdleConnTimeout,
+		MaxIdleConns:          t.MaxIdleConns,
+		MaxIdleConnsPerHost:   t.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       t.MaxConnsPerHost,
 	}
 }
 
-func (r *Run) runTrialSingleIP() {
-	args := r.Args
-	if args.Ipv6 != "" {
-		logger.Fatal("This features only work in paid plans")
-		return
+// timeWindow parses --since and --until, relative to now.
+func timeWindow(args args.Options) (client.TimeWindow, error) {
+	now := time.Now()
+	since, err := utils.ParseTime(args.Since, now)
+	if err != nil {
+		return client.TimeWindow{}, fmt.Errorf("--since: %w", err)
 	}
-	argMap := map[string]string{
-		"ip":    args.Ipv4,
-		"ns":    args.Ns,
-		"cname": args.Cname,
-		"txt":   args.Txt,
-		"mx":    args.Mx,
+	until, err := utils.ParseTime(args.Until, now)
+	if err != nil {
+		return client.TimeWindow{}, fmt.Errorf("--until: %w", err)
 	}
-
-	for k, v := range argMap {
-		if v != "" {
-			r.fetchAndSaveRecords(k, v)
-			return
-		}
+	if !since.IsZero() && !until.IsZero() && until.Before(since) {
+		return client.TimeWindow{}, fmt.Errorf("--until %s is before --since %s", until.Format(time.RFC3339), since.Format(time.RFC3339))
+	}
+	if !since.IsZero() || !until.IsZero() {
+		logger.WithFields(map[string]any{
+			"since": since,
+			"until": until,
+		}).Info("Filtering records by time window")
 	}
-	logger.Fatal("You must provide at least one of the following: --ip, --ns, --cname, --txt, --mx")
+	return client.TimeWindow{Since: since, Until: until}, nil
 }
 
-func (r *Run) runTrialFromFile() {
-	stream := StreamFile(r.Args.ListFile)
-	jobs := make(chan string, r.Args.Threads*2)
-
-	var wg sync.WaitGroup
-	for i := 0; i < r.Args.Threads; i++ {
-		wg.Add(1)
-		go r.runWorker(jobs, &wg, i, func(param, target string) {
+// Query fetches the records of the target flag with a single request.
+func (r *Run) Query() ExitStatus {
+	param, target := r.Args.Target()
+	return r.start(func() {
+		r.trackTarget(0, target, func() {
 			r.fetchAndSaveRecords(param, target)
 		})
+	})
+}
+
+// Stream pages through the records of the target flag. The trial API has
+// no paging, so trial runs fall back to a single query.
+func (r *Run) Stream() ExitStatus {
+	if r.Args.Trial {
+		logger.Warn("The trial API has no paging, running a single query instead")
+		return r.Query()
 	}
+	param, target := r.Args.Target()
+	return r.start(func() {
+		r.trackTarget(0, target, func() {
+			r.processStreamRecords(param, target)
+		})
+	})
+}
 
-	for line := range stream {
-		if trimmed := strings.TrimSpace(line); trimmed != "" {
-			jobs <- trimmed
+// Bulk queries every target of the list file.
+func (r *Run) Bulk() ExitStatus {
+	if r.Args.Trial {
+		return r.start(r.runTrialFromFile) // a.k.a bulk scan from file
+	}
+	return r.start(r.runBulkScanFromFile)
+}
+
+// Profile streams the full mode A or AAAA records of the ip.
+func (r *Run) Profile() ExitStatus {
+	if r.Args.Trial {
+		if r.Args.Ipv6 != "" {
+			logger.Fatal("This features only work in paid plans")
 		}
+		logger.Warn("Full mode only works in paid plans, running a single query instead")
+		return r.Query()
 	}
+	if r.Args.Ipv6 != "" {
+		return r.start(func() { r.runFullIPv6Scan(r.Args.Ipv6) })
+	}
+	return r.start(func() { r.runFullIPv4Scan(r.Args.Ipv4) })
+}
 
-	close(jobs)
-	wg.Wait()
+// start runs mode and returns the exit status recorded in the run summary.
+func (r *Run) start(mode func()) ExitStatus {
+	ctx, cancel := r.runContext(context.Background())
+	defer cancel()
+	r.ctx = ctx
+	r.Progress = r.newProgress()
+	r.Progress.Start()
+
+	mode()
+
+	r.closeSinks()
+	r.Progress.Stop()
+	return r.writeSummary()
+}
+
+func (r *Run) runTrialFromFile() {
+	r.runListFile(func(param, target string) {
+		r.fetchAndSaveRecords(param, target)
+	})
 }
 
 func (r *Run) runFullIPv6Scan(ipv6 string) {
-	r.fetchAAAARecordStream(ipv6)
+	r.trackTarget(0, ipv6, func() {
+		r.fetchAAAARecordStream(ipv6)
+	})
 }
 
 func (r *Run) runFullIPv4Scan(ipv4 string) {
-	r.fetchARecordStream(ipv4)
+	r.trackTarget(0, ipv4, func() {
+		r.fetchARecordStream(ipv4)
+	})
 }
 
 func (r *Run) runBulkScanFromFile() {
+	r.runListFile(func(param, target string) {
+		r.processStreamRecords(param, target)
+	})
+}
+
+// runListFile runs handler for every target of the list file on --threads
+// workers. With --adaptive, --max-threads workers are started and the
+// concurrency controller decides how many of them are active.
+func (r *Run) runListFile(handler func(param, target string)) {
 	stream := StreamFile(r.Args.ListFile)
-	jobs := make(chan string, r.Args.Threads*2)
+	workers := r.Args.Threads
+	if r.concurrency != nil {
+		workers = r.Args.MaxThreads
+		r.Progress.TrackConcurrency(r.concurrency.Limit)
+		logger.Infof("Adaptive concurrency between %d and %d threads, starting at %d", r.Args.MinThreads, r.Args.MaxThreads, r.Args.Threads)
+	}
+	jobs := make(chan string, workers*2)
 
 	var wg sync.WaitGroup
-	for i := 0; i < r.Args.Threads; i++ {
+	for i := 0; i < workers; i++ {
 		wg.Add(1)
-		go r.runWorker(jobs, &wg, i, func(param, target string) {
-			r.processStreamRecords(param, target)
-		})
+		go r.runWorker(jobs, &wg, i, handler)
 	}
 
 	// Feed jobs
@@ -137,39 +334,48 @@ func (r *Run) runBulkScanFromFile() {
 
 	close(jobs)
 	wg.Wait()
-
+	if r.ctx.Err() != nil {
+		logger.Warn("Run deadline reached, the targets not done are truncated in the run summary")
+	}
 }
 
-func (r *Run) runSingleIPScan() {
-	args := r.Args
-
-	if args.Ipv6 != "" {
-		if !args.ModeFull {
-			logger.Fatal("You must use --full, -f to query ipv6")
-		}
-		r.fetchAAAARecordStream(args.Ipv6)
-		return
+// newProgress creates the progress tracker for this run. For list files the
+// number of targets is counted up front so done/total and the ETA are known.
+func (r *Run) newProgress() *progress.Tracker {
+	if r.Args.NoProgress {
+		return progress.Disabled()
 	}
 
-	if args.Ipv4 != "" && args.ModeFull {
-		r.fetchARecordStream(args.Ipv4)
-		return
+	total := 1
+	if r.Args.ListFile != "" {
+		n, err := CountLines(r.Args.ListFile)
+		if err != nil {
+			logger.Warnf("failed to count targets in %s: %v", r.Args.ListFile, err)
+		}
+		total = n
 	}
 
-	argMap := map[string]string{
-		"ip":    args.Ipv4,
-		"ns":    args.Ns,
-		"cname": args.Cname,
-		"txt":   args.Txt,
-		"mx":    args.Mx,
+	p := progress.New(total, os.Stderr)
+	// single query runs do not honour --max, so only streamed targets have a known record count
+	if r.Args.ListFile == "" && !r.Args.Trial {
+		p.SetRecordLimit(r.Args.MaxTotalOutputIp)
 	}
+	return p
+}
 
-	for k, v := range argMap {
-		if v != "" {
-			r.processStreamRecords(k, v)
-			return
+// logsToStdout reports whether conf writes logs to stdout.
+func logsToStdout(conf cfg.Conf) bool {
+	for _, out := range conf.Log.Stdout {
+		if out.Output == cfg.LogOutputStdout {
+			return true
 		}
 	}
+	return false
+}
 
-	logger.Fatal("You must provide at least one of the following: --ip, --ns, --cname, --txt, --mx")
+// trackTarget reports target as the current work of workerID while fn runs.
+func (r *Run) trackTarget(workerID int, target string, fn func()) {
+	r.Progress.SetWorker(workerID, target)
+	defer r.Progress.TargetDone(workerID)
+	fn()
 }
@@ -0,0 +1,90 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/stretchr/testify/require"
+)
+
+// pagingAPI serves pages of two records for every query. An ip or ipv6
+// query returns the domains d<n>.<ip>, any other query the ips 10.0.<page>.<n>
+// of the domain. shift renumbers the records, and with stall set every page
+// after the first hangs until the request is cancelled.
+type pagingAPI struct {
+	pages    int
+	shift    atomic.Int32
+	stall    bool
+	requests atomic.Int32
+}
+
+func (a *pagingAPI) start(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		a.requests.Add(1)
+		page, _ := strconv.Atoi(r.URL.Query().Get("page_token"))
+		if a.stall && page > 0 {
+			<-r.Context().Done()
+			return
+		}
+
+		resp := model.RecordsResponse{Pagination: model.PaginationMetadata{HasMore: page < a.pages-1, NextPageToken: strconv.Itoa(page + 1)}}
+		for i := 1; i <= 2; i++ {
+			n := page*2 + i + int(a.shift.Load())
+			record := model.Record{RecordType: "A", Timestamp: int64(n)}
+			switch {
+			case r.URL.Path == "/api/dns/aaaa":
+				record.IP, record.DomainID = r.URL.Query().Get("ipv6"), fmt.Sprintf("d%d.v6.test", n)
+			case r.URL.Query().Has("ip"):
+				record.IP, record.DomainID = r.URL.Query().Get("ip"), fmt.Sprintf("d%d.%s", n, r.URL.Query().Get("ip"))
+			default:
+				record.DomainID = r.URL.Query().Get(paramOf(r))
+				record.IP = fmt.Sprintf("10.0.%d.%d", page, n)
+			}
+			resp.Data = append(resp.Data, record)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// paramOf returns the target parameter of a request to the paging endpoint.
+func paramOf(r *http.Request) string {
+	for _, param := range []string{"domain", "ns", "mx", "cname", "txt"} {
+		if r.URL.Query().Has(param) {
+			return param
+		}
+	}
+	return ""
+}
+
+// newTestRun creates a run against host that writes nothing but its output
+// into a temporary dir.
+func newTestRun(t *testing.T, host string, opts args.Options) *Run {
+	conf := cfg.GetDefaultConf()
+	conf.Api.Host = host
+	conf.Api.Apikey = "test-key"
+	conf.Api.KeyUsage = ""
+	conf.Usage.Ledger = ""
+	conf.Output.Dir = t.TempDir()
+	conf.Output.Summary = ""
+
+	if opts.PageSize == 0 {
+		opts.PageSize = 2
+	}
+	if opts.Threads == 0 {
+		opts.Threads = 1
+	}
+	opts.NoProgress = true
+	r, err := NewRun(opts, conf)
+	require.NoError(t, err)
+	return r
+}
@@ -0,0 +1,208 @@
+package run
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/webhook"
+)
+
+// newSinks creates the sinks enabled in the config or args.
+func newSinks(args args.Options, conf cfg.Conf) ([]Sink, error) {
+	var sinks []Sink
+	if conf.Output.Webhook.URL != "" {
+		hook := webhookConfig(conf)
+		if hook.DeadLetter != "" {
+			if err := fileutil.EnsureDir(filepath.Dir(hook.DeadLetter)); err != nil {
+				return nil, err
+			}
+		}
+		sender, err := webhook.New(hook, nil)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sender)
+	}
+
+	path := conf.Output.Graph
+	if args.Graph != "" {
+		path = args.Graph
+	}
+	if path := graphPath(path, conf.Output.Dir); path != "" {
+		sink, err := newGraphSink(path)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if args.CidrFormat != "" {
+		sink, err := newCidrSink(args.CidrFormat, args.CidrByASN, newReportOut(conf, "cidr", args.CidrFormat))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if args.Aggregate != "" {
+		sink, err := newAggregateSink(args.Aggregate, newReportOut(conf, "aggregate", args.Aggregate))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// reportOut is where a report printed once the run is done goes: stdout,
+// or a file in the output dir while the logs are written to stdout, so the
+// report is not mixed with log lines.
+type reportOut struct {
+	// path is the report file, empty for stdout
+	path string
+}
+
+// newReportOut returns the output of the report name in format.
+func newReportOut(conf cfg.Conf, name, format string) reportOut {
+	if !logsToStdout(conf) {
+		return reportOut{}
+	}
+	ext := format
+	if format == "table" || format == "list" {
+		ext = "txt"
+	}
+	return reportOut{path: filepath.Join(conf.Output.Dir, name+"."+ext)}
+}
+
+// write renders the report with fn.
+func (o reportOut) write(fn func(w io.Writer) error) error {
+	if o.path == "" {
+		return fn(os.Stdout)
+	}
+	if err := fileutil.EnsureDir(filepath.Dir(o.path)); err != nil {
+		return err
+	}
+	f, err := os.Create(o.path)
+	if err != nil {
+		return err
+	}
+	if err := fn(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	logger.Infof("Report written to %s", o.path)
+	return nil
+}
+
+// webhookConfig converts the webhook config, a relative dead letter path
+// is placed inside the output dir like the run summary.
+func webhookConfig(conf cfg.Conf) webhook.Config {
+	w := conf.Output.Webhook
+	deadLetter := w.DeadLetter
+	if deadLetter != "" && !filepath.IsAbs(deadLetter) {
+		deadLetter = filepath.Join(conf.Output.Dir, deadLetter)
+	}
+	return webhook.Config{
+		URL:           w.URL,
+		Secret:        w.Secret,
+		Template:      w.Template,
+		Headers:       w.Headers,
+		BatchSize:     w.BatchSize,
+		FlushInterval: w.FlushInterval,
+		MaxRetries:    w.MaxRetries,
+		Backoff:       w.Backoff,
+		Timeout:       w.Timeout,
+		DeadLetter:    deadLetter,
+	}
+}
+
+// emitting reports whether records have to be passed to emit at all.
+func (r *Run) emitting() bool {
+	return r.Args.Output || len(r.Sinks) > 0
+}
+
+// emit enriches the record of task and writes it to the output file when
+// --output is set and to every sink.
+func (r *Run) emit(task SaveTask) {
+	if record, ok := task.Data.(model.Record); ok {
+		task.Data = r.enrich(record)
+	}
+
+	if r.Args.Output {
+		r.writeOutput(task)
+	}
+
+	for _, sink := range r.Sinks {
+		if err := sink.Write(task.Data); err != nil {
+			logger.Warnf("sink write error: %v", err)
+			r.Report.AddSaveError()
+		}
+	}
+}
+
+// writeOutput writes the record of task to its output file in the format
+// of the task.
+func (r *Run) writeOutput(task SaveTask) {
+	path := task.Path
+	if r.Args.GroupBy != "" {
+		var err error
+		if path, err = r.groupedPath(task.Data, path); err != nil {
+			logger.Warnf("failed to group record: %v", err)
+			r.Report.AddSaveError()
+			path = task.Path
+		}
+	}
+
+	switch task.Format {
+	case "geojson":
+		r.addLocation(task.Data, path)
+	case "txt":
+		data := task.Data
+		if record, ok := data.(HasDomainID); ok {
+			data = record.GetDomainID()
+		}
+		r.save(data, path)
+	case "template":
+		line, err := r.lineTmpl.Execute(task.Data)
+		if err != nil {
+			logger.Warnf("failed to render record: %v", err)
+			r.Report.AddSaveError()
+			return
+		}
+		// template lines are plain text
+		r.save(line, strings.TrimSuffix(path, filepath.Ext(path))+".txt")
+	default:
+		r.save(r.renderTimestamp(task.Data), path)
+	}
+}
+
+// closeSinks flushes and closes every sink, writes the geojson output and
+// closes the enrichment databases.
+func (r *Run) closeSinks() {
+	r.writeGeoJSON()
+	for _, sink := range r.Sinks {
+		if err := sink.Close(); err != nil {
+			logger.Warnf("sink close error: %v", err)
+			r.Report.AddSaveError()
+			continue
+		}
+		if graph, ok := sink.(*graphSink); ok {
+			r.Report.AddOutputFile(graph.path)
+		}
+	}
+	if r.Enricher != nil {
+		if err := r.Enricher.Close(); err != nil {
+			logger.Warnf("enrichment database close error: %v", err)
+		}
+	}
+}
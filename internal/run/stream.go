@@ -2,18 +2,25 @@ package run
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
 
 	"github.com/Doom-z/RepClient/client"
-	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/client/model"
 	"github.com/Doom-z/RepClient/pkg/logger"
 	"github.com/Doom-z/RepClient/pkg/utils"
 )
 
 func StreamFile(file string) <-chan string {
+	return StreamFileContext(context.Background(), file)
+}
+
+// StreamFileContext is StreamFile stopping once ctx is done, so callers can
+// stop reading early without leaking the reader.
+func StreamFileContext(ctx context.Context, file string) <-chan string {
 	out := make(chan string)
 
 	go func() {
@@ -29,8 +36,13 @@ func StreamFile(file string) <-chan string {
 		scanner := bufio.NewScanner(f)
 		for scanner.Scan() {
 			line := scanner.Text()
-			if line != "" {
-				out <- line
+			if line == "" {
+				continue
+			}
+			select {
+			case out <- line:
+			case <-ctx.Done():
+				return
 			}
 		}
 
@@ -42,10 +54,35 @@ func StreamFile(file string) <-chan string {
 	return out
 }
 
+// CountLines returns the number of non-blank lines in file.
+func CountLines(file string) (int, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	count := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) != "" {
+			count++
+		}
+	}
+	return count, scanner.Err()
+}
+
 func (r *Run) handleStreamInput(input string, handler func(param string, target string)) {
 	param := utils.DetectRecordType(input)
 	if param == "" {
 		logger.Warnf("Could not detect record type for: %s", input)
+		r.targetError(input, fmt.Errorf("could not detect record type"))
+		return
+	}
+	if r.ctx.Err() != nil {
+		// the run deadline passed before the target started
+		r.Report.Begin(param, input)
+		r.Report.Truncate(input, TruncatedDeadline, "")
 		return
 	}
 	handler(param, input)
@@ -53,77 +90,39 @@ func (r *Run) handleStreamInput(input string, handler func(param string, target
 
 func (r *Run) processStreamRecords(param, target string) {
 	logger.Tracef("Fetching (%s) records for %s with max records: %d", param, target, r.Args.MaxTotalOutputIp)
+	r.Report.Begin(param, target)
 
-	recordsCh, errCh := r.Client.FetchRecordsStream(param, target)
-	count := 0
 	pageSize := r.Args.PageSize
-	max := r.Args.MaxTotalOutputIp
 	outputPath := fmt.Sprintf("%s/stream.%s", r.Cfg.Output.Dir, r.Cfg.Output.Format)
 
 	saveCh := make(chan SaveTask, r.Args.PageSize)
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		for task := range saveCh {
-			switch task.Format {
-			case "txt":
-				if s, ok := task.Data.(string); ok {
-					fileutil.SaveData(s, task.Path, "append")
-				}
-			default:
-				fileutil.SaveData(task.Data, task.Path, "append")
-			}
-		}
-	}()
-
-	for {
-		select {
-		case record, ok := <-recordsCh:
-			if !ok {
-				if count > 0 {
-					logger.WithGID().Debugf("Fetched %d (%s) records for %s", count, param, target)
-				}
-				recordsCh = nil
-			} else {
-				count++
-				if r.Args.Output {
-					var data any
-					if r.Cfg.Output.Format == "txt" {
-						data = record.DomainID
-					} else {
-						data = record
-					}
-
-					select {
-					case saveCh <- SaveTask{Data: data, Format: r.Cfg.Output.Format, Path: outputPath}:
-					default:
-						logger.Warnf("Save channel full, dropping record: %v", data)
-					}
-				}
-
-				logger.WithGID().Tracef("%s -> %s (%s) at %d", record.IP, record.DomainID, record.RecordType, record.Timestamp)
-
-				if count%pageSize == 0 {
-					logger.WithGID().Debugf("Fetched %d (%s) records for %s", count, param, target)
-				}
-
-				if max > 0 && count >= max {
-					recordsCh = nil
-					errCh = nil
-				}
-			}
+	go r.startSaveWorker(&wg, saveCh)
 
-		case err, ok := <-errCh:
-			if ok && err != nil {
-				logger.Warnf("Client fetch error: %v", err)
-			}
-			errCh = nil
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[model.Record], <-chan error) {
+		return r.Client.FetchRecordPages(ctx, param, target, pageToken)
+	}
+	count := 0
+	_, _, err := streamPages(r, target, fetch, func(record model.Record) {
+		count++
+		r.recordFound(target, record.RecordType)
+		if r.emitting() {
+			// block instead of dropping records when a slow sink such as a webhook falls behind
+			saveCh <- SaveTask{Data: record, Format: r.Cfg.Output.Format, Path: outputPath}
 		}
 
-		if recordsCh == nil && errCh == nil {
-			break
+		logger.WithGID().Tracef("%s -> %s (%s) at %s", record.IP, record.DomainID, record.RecordType, r.timeMode.Format(record.Timestamp))
+
+		if count%pageSize == 0 {
+			logger.WithGID().Debugf("Fetched %d (%s) records for %s", count, param, target)
 		}
+	})
+	if err != nil {
+		logger.Warnf("Client fetch error: %v", err)
+		r.targetError(target, err)
+	} else if count > 0 {
+		logger.WithGID().Debugf("Fetched %d (%s) records for %s", count, param, target)
 	}
 
 	close(saveCh)
@@ -136,52 +135,62 @@ func (r *Run) processStreamRecords(param, target string) {
 	}).Infof("Successfully fetched all records")
 }
 
+// fetchRecords streams the enriched records of target into fn within the
+// budgets of the run, see streamPages. It returns the budget that stopped
+// the target, empty when all its records were read, and the fetch error.
+func (r *Run) fetchRecords(param, target string, fn func(model.Record)) (string, error) {
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[model.Record], <-chan error) {
+		return r.Client.FetchRecordPages(ctx, param, target, pageToken)
+	}
+	_, reason, err := streamPages(r, target, fetch, func(record model.Record) {
+		fn(r.enrich(record))
+	})
+	return reason, err
+}
+
+// fetchAAAARecords is fetchRecords for an IPv6 address, which only the full
+// mode AAAA endpoint takes. Its records come with the ASN and country set and
+// are passed on as plain AAAA records.
+func (r *Run) fetchAAAARecords(ipv6 string, fn func(model.Record)) (string, error) {
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[model.AAAARecord], <-chan error) {
+		return client.FetchDNSRecordPages[model.AAAARecord](ctx, r.Client, "aaaa", ipv6, pageToken)
+	}
+	_, reason, err := streamPages(r, ipv6, fetch, func(record model.AAAARecord) {
+		fn(model.Record{
+			IP:         record.IP,
+			DomainID:   record.DomainID,
+			RecordType: "AAAA",
+			Timestamp:  record.Timestamp,
+			ASN:        record.ASN,
+			ASNName:    record.ASNName,
+			Country:    record.Country,
+			City:       record.City,
+		})
+	})
+	return reason, err
+}
+
 func processTypedStream[T HasDomainID](
-	c *client.Client,
+	r *Run,
 	recordType, ip string,
 	logFn func(T),
 	saveCh chan<- SaveTask,
 	outputPath, format string,
 	shouldSave bool,
-) {
-	recordsCh, errCh := client.FetchDNSRecords[T](c, recordType, ip)
-
-	count := 0
-	for {
-		select {
-		case record, ok := <-recordsCh:
-			if !ok {
-				recordsCh = nil
-				continue
-			}
-			count++
-			logFn(record)
-
-			if shouldSave {
-				var data any
-				if format == "txt" {
-					data = record.GetDomainID()
-				} else {
-					data = record
-				}
-
-				saveCh <- SaveTask{
-					Data:   data,
-					Path:   outputPath,
-					Format: format,
-				}
-			}
-
-		case err, ok := <-errCh:
-			if ok && err != nil {
-				logger.Fatalf("Client fetch error: %v", err)
+) error {
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[T], <-chan error) {
+		return client.FetchDNSRecordPages[T](ctx, r.Client, recordType, ip, pageToken)
+	}
+	count, _, err := streamPages(r, ip, fetch, func(record T) {
+		logFn(record)
+		if shouldSave {
+			saveCh <- SaveTask{
+				Data:   record,
+				Path:   outputPath,
+				Format: format,
 			}
-			errCh = nil
 		}
-
-		if recordsCh == nil && errCh == nil {
-			break
-		}
-	}
+	})
 	logger.Infof("Total %s records for %s: %d", strings.ToUpper(recordType), ip, count)
+	return err
 }
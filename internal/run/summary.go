@@ -0,0 +1,373 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/client"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/Doom-z/RepClient/pkg/aimd"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/usage"
+	"github.com/Doom-z/RepClient/pkg/utils"
+)
+
+type ExitStatus string
+
+const (
+	ExitSuccess ExitStatus = "success"
+	ExitPartial ExitStatus = "partial"
+	ExitFailed  ExitStatus = "failed"
+)
+
+// Code returns the process exit code for the status.
+func (s ExitStatus) Code() int {
+	switch s {
+	case ExitFailed:
+		return 1
+	case ExitPartial:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// Summary is the machine readable report written at the end of every run.
+type Summary struct {
+	Config      cfg.Conf         `json:"config"`
+	Args        args.Options     `json:"args"`
+	StartedAt   time.Time        `json:"started_at"`
+	FinishedAt  time.Time        `json:"finished_at"`
+	Duration    string           `json:"duration"`
+	Targets     []*TargetSummary `json:"targets"`
+	Totals      map[string]int   `json:"totals"`
+	Records     int              `json:"records"`
+	SaveErrors  int              `json:"save_errors"`
+	OutputFiles []OutputFile     `json:"output_files"`
+	ExitStatus  ExitStatus       `json:"exit_status"`
+
+	// SkippedLocations counts the records left out of the geojson output
+	// for a missing or malformed LatLong.
+	SkippedLocations int `json:"skipped_locations,omitempty"`
+	// Profile is the config profile the run used
+	Profile string `json:"profile,omitempty"`
+	// Keys are the calls of every key of the api key pool
+	Keys []KeySummary `json:"keys,omitempty"`
+	// Usage are the requests, pages and records per endpoint and api key
+	Usage []usage.Entry `json:"usage,omitempty"`
+	// Hosts are the requests and pages served by every API host
+	Hosts []client.HostStats `json:"hosts,omitempty"`
+	// Concurrency are the adjustments of an --adaptive run
+	Concurrency *aimd.Stats `json:"concurrency,omitempty"`
+	// Truncated counts the targets stopped by a budget
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// KeySummary is the usage of an api key of the pool, with the key redacted.
+type KeySummary struct {
+	Key string `json:"key"`
+	client.KeyStats
+}
+
+type TargetSummary struct {
+	Target  string         `json:"target"`
+	Param   string         `json:"param,omitempty"`
+	Records int            `json:"records"`
+	Totals  map[string]int `json:"totals"`
+	Errors  []string       `json:"errors,omitempty"`
+	// Truncated is the budget the target was stopped by: records, pages,
+	// time or deadline. PageToken resumes it with --page-token, it is empty
+	// when the target has to start over.
+	Truncated string `json:"truncated,omitempty"`
+	PageToken string `json:"page_token,omitempty"`
+}
+
+type OutputFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Report collects per target results while a run is in progress.
+type Report struct {
+	mu               sync.Mutex
+	startedAt        time.Time
+	targets          map[string]*TargetSummary
+	order            []string
+	outputFiles      map[string]struct{}
+	saveErrors       int
+	skippedLocations int
+}
+
+func NewReport() *Report {
+	return &Report{
+		startedAt:   time.Now(),
+		targets:     map[string]*TargetSummary{},
+		outputFiles: map[string]struct{}{},
+	}
+}
+
+// target returns the summary entry of target, creating it when needed.
+// The caller must hold the lock.
+func (rep *Report) target(target string) *TargetSummary {
+	t, ok := rep.targets[target]
+	if !ok {
+		t = &TargetSummary{Target: target, Totals: map[string]int{}}
+		rep.targets[target] = t
+		rep.order = append(rep.order, target)
+	}
+	return t
+}
+
+// Begin registers target and the query parameter used to fetch it.
+func (rep *Report) Begin(param, target string) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.target(target).Param = param
+}
+
+func (rep *Report) AddRecord(target, recordType string) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	t := rep.target(target)
+	t.Records++
+	t.Totals[strings.ToUpper(recordType)]++
+}
+
+func (rep *Report) AddError(target string, err error) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	t := rep.target(target)
+	t.Errors = append(t.Errors, err.Error())
+}
+
+// Truncate marks target as stopped by the budget reason, to be resumed at
+// pageToken.
+func (rep *Report) Truncate(target, reason, pageToken string) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	t := rep.target(target)
+	t.Truncated, t.PageToken = reason, pageToken
+}
+
+func (rep *Report) AddOutputFile(path string) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.outputFiles[path] = struct{}{}
+}
+
+func (rep *Report) AddSaveError() {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.saveErrors++
+}
+
+func (rep *Report) AddSkippedLocations(n int) {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	rep.skippedLocations += n
+}
+
+// Summary builds the final summary. Output files are hashed at this point,
+// so it must only be called once every writer is done.
+func (rep *Report) Summary(conf cfg.Conf, a args.Options) Summary {
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+
+	finishedAt := time.Now()
+	s := Summary{
+		Config:     conf.Redacted(),
+		Args:       a,
+		StartedAt:  rep.startedAt,
+		FinishedAt: finishedAt,
+		Duration:   finishedAt.Sub(rep.startedAt).Round(time.Millisecond).String(),
+		Targets:    make([]*TargetSummary, 0, len(rep.order)),
+		Totals:     map[string]int{},
+		SaveErrors: rep.saveErrors,
+
+		SkippedLocations: rep.skippedLocations,
+		Profile:          conf.Profile,
+	}
+
+	failed, incomplete := 0, 0
+	for _, name := range rep.order {
+		t := rep.targets[name]
+		s.Targets = append(s.Targets, t)
+		s.Records += t.Records
+		for recordType, n := range t.Totals {
+			s.Totals[recordType] += n
+		}
+		if len(t.Errors) > 0 {
+			failed++
+		}
+		if t.Truncated != "" {
+			s.Truncated++
+		}
+		if t.Truncated != "" && t.Truncated != TruncatedRecords {
+			incomplete++
+		}
+	}
+
+	paths := make([]string, 0, len(rep.outputFiles))
+	for path := range rep.outputFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		file, err := describeFile(path)
+		if err != nil {
+			logger.Warnf("failed to describe output file %s: %v", path, err)
+			continue
+		}
+		s.OutputFiles = append(s.OutputFiles, file)
+	}
+
+	switch {
+	case len(s.Targets) > 0 && failed == len(s.Targets) && s.Records == 0:
+		s.ExitStatus = ExitFailed
+	// --max is a cap on the results, the other budgets leave records unread
+	case failed > 0 || s.SaveErrors > 0 || incomplete > 0:
+		s.ExitStatus = ExitPartial
+	default:
+		s.ExitStatus = ExitSuccess
+	}
+	return s
+}
+
+func describeFile(path string) (OutputFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return OutputFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return OutputFile{}, err
+	}
+	return OutputFile{
+		Path:   path,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// summaryPath resolves where the summary is written, relative paths are
+// placed inside the output dir. An empty path disables the summary.
+func (r *Run) summaryPath() string {
+	path := r.Cfg.Output.Summary
+	if r.Args.Summary != "" {
+		path = r.Args.Summary
+	}
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.Cfg.Output.Dir, path)
+}
+
+// writeSummary writes the run summary and returns the exit status of the run.
+func (r *Run) writeSummary() ExitStatus {
+	summary := r.Report.Summary(r.Cfg, r.Args)
+	r.saveUsage()
+	summary.Keys = r.keySummary()
+	summary.Usage = r.meter.Entries()
+	if len(r.Cfg.Api.Hosts) > 0 {
+		summary.Hosts = r.Client.HostStats()
+	}
+	if r.concurrency != nil {
+		stats := r.concurrency.Stats()
+		summary.Concurrency = &stats
+	}
+
+	fields := map[string]any{
+		"targets": len(summary.Targets),
+		"records": summary.Records,
+		"status":  summary.ExitStatus,
+	}
+	if summary.Profile != "" {
+		fields["profile"] = summary.Profile
+	}
+	if summary.Concurrency != nil {
+		fields["concurrency"] = summary.Concurrency.Limit
+	}
+	if summary.Truncated > 0 {
+		fields["truncated"] = summary.Truncated
+	}
+	logger.WithFields(fields).Info("Run finished")
+
+	path := r.summaryPath()
+	if path == "" {
+		return summary.ExitStatus
+	}
+	if err := fileutil.EnsureDir(filepath.Dir(path)); err != nil {
+		logger.Warnf("failed to create summary dir: %v", err)
+		return summary.ExitStatus
+	}
+	if err := fileutil.SaveData(summary, path, "overwrite"); err != nil {
+		logger.Warnf("failed to write summary %s: %v", path, err)
+		return summary.ExitStatus
+	}
+	logger.Debugf("Run summary written to %s", path)
+	return summary.ExitStatus
+}
+
+// save writes data to path and records the file for the run summary.
+func (r *Run) save(data any, path string) {
+	if err := fileutil.SaveData(data, path, "append"); err != nil {
+		logger.Warnf("failed to save record to %s: %v", path, err)
+		r.Report.AddSaveError()
+		return
+	}
+	r.Report.AddOutputFile(path)
+}
+
+// recordFound accounts a record of recordType received for target.
+func (r *Run) recordFound(target, recordType string) {
+	r.Progress.AddRecords(1)
+	r.Report.AddRecord(target, recordType)
+}
+
+// targetError accounts a failure while processing target.
+func (r *Run) targetError(target string, err error) {
+	r.Progress.AddError()
+	r.Report.AddError(target, err)
+}
+
+// keySummary returns the usage of every key of the api key pool.
+func (r *Run) keySummary() []KeySummary {
+	if r.keyPool == nil {
+		return nil
+	}
+	var keys []KeySummary
+	for i, stats := range r.keyPool.Stats() {
+		keys = append(keys, KeySummary{Key: utils.RedactSecret(r.Cfg.Api.Keys[i].Key), KeyStats: stats})
+	}
+	return keys
+}
+
+// saveUsage adds the requests since the last save to the usage ledger and
+// writes the daily requests of the api key pool, so limits and budgets
+// hold across runs.
+func (r *Run) saveUsage() {
+	if r.Cfg.Usage.Ledger != "" {
+		if err := (usage.Ledger{Path: r.Cfg.Usage.Ledger}).Add(r.meter.Flush()); err != nil {
+			logger.Warnf("failed to save usage ledger: %v", err)
+		}
+	}
+	if r.keyPool == nil {
+		return
+	}
+	if err := r.keyPool.Save(); err != nil {
+		logger.Warnf("failed to save api key usage: %v", err)
+	}
+}
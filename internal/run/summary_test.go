@@ -0,0 +1,58 @@
+package run
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/cmd/app/cfg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportSummaryExitStatus(t *testing.T) {
+	failure := errors.New("boom")
+	tests := []struct {
+		name      string
+		report    func(rep *Report)
+		status    ExitStatus
+		truncated int
+	}{
+		{"no targets", func(rep *Report) {}, ExitSuccess, 0},
+		{"records", func(rep *Report) {
+			rep.AddRecord("1.1.1.1", "a")
+		}, ExitSuccess, 0},
+		{"capped by --max", func(rep *Report) {
+			rep.AddRecord("1.1.1.1", "a")
+			rep.Truncate("1.1.1.1", TruncatedRecords, "1")
+		}, ExitSuccess, 1},
+		{"stopped by the time budget", func(rep *Report) {
+			rep.AddRecord("1.1.1.1", "a")
+			rep.Truncate("1.1.1.1", TruncatedTime, "1")
+		}, ExitPartial, 1},
+		{"stopped by the deadline", func(rep *Report) {
+			rep.Begin("ip", "1.1.1.1")
+			rep.Truncate("1.1.1.1", TruncatedDeadline, "")
+		}, ExitPartial, 1},
+		{"one target failed", func(rep *Report) {
+			rep.AddRecord("1.1.1.1", "a")
+			rep.AddError("2.2.2.2", failure)
+		}, ExitPartial, 0},
+		{"save error", func(rep *Report) {
+			rep.AddRecord("1.1.1.1", "a")
+			rep.AddSaveError()
+		}, ExitPartial, 0},
+		{"every target failed", func(rep *Report) {
+			rep.AddError("1.1.1.1", failure)
+			rep.AddError("2.2.2.2", failure)
+		}, ExitFailed, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep := NewReport()
+			tt.report(rep)
+			s := rep.Summary(cfg.GetDefaultConf(), args.Options{})
+			assert.Equal(t, tt.status, s.ExitStatus)
+			assert.Equal(t, tt.truncated, s.Truncated)
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package run
+
+import (
+	"maps"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/timefmt"
+)
+
+// renderTimestamp returns record in the map form with its timestamp
+// rendered in the configured mode. Records are returned as is in epoch mode.
+func (r *Run) renderTimestamp(record any) any {
+	if r.timeMode == timefmt.Epoch {
+		return record
+	}
+	if _, ok := record.(model.Timestamped); !ok {
+		return record
+	}
+	m, err := fileutil.ToMap(record)
+	if err != nil {
+		logger.Warnf("failed to render timestamp: %v", err)
+		return record
+	}
+	r.timeMode.Apply(m)
+	return m
+}
+
+// timeFields adds the timestamp ts to the log fields of a record.
+func (r *Run) timeFields(fields map[string]any, ts int64) map[string]any {
+	maps.Copy(fields, r.timeMode.Fields(ts))
+	return fields
+}
@@ -4,6 +4,16 @@ type HasDomainID interface {
 	GetDomainID() string
 }
 
+// Sink receives every record of a run next to the file output,
+// e.g. a webhook.
+type Sink interface {
+	Write(record any) error
+	Close() error
+}
+
+// SaveTask is a record waiting to be written, Data is the record itself and
+// is reduced to its domain when Format is txt, or rendered with the output
+// template when Format is template.
 type SaveTask struct {
 	Data   any
 	Path   string
@@ -0,0 +1,231 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Doom-z/RepClient/client"
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/internal/notify"
+	"github.com/Doom-z/RepClient/internal/watch"
+	"github.com/Doom-z/RepClient/pkg/diff"
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Watch re-queries the targets of the list file every cmd.Every until
+// interrupted. The last result of every target is kept in the state dir and
+// only the differences to it are sent to the notifiers. The run summary is
+// rewritten after every cycle, Watch returns the exit status of the last one.
+func (r *Run) Watch(cmd args.WatchCmd) (ExitStatus, error) {
+	if r.Args.ListFile == "" {
+		return ExitFailed, fmt.Errorf("watch requires a list of targets, use --list-file")
+	}
+
+	stateDir := r.Cfg.Watch.StateDir
+	if cmd.StateDir != "" {
+		stateDir = cmd.StateDir
+	}
+	store, err := watch.NewStore(stateDir)
+	if err != nil {
+		return ExitFailed, fmt.Errorf("state dir init error: %w", err)
+	}
+
+	specs := r.Cfg.Watch.Notify
+	if len(cmd.Notify) > 0 {
+		specs = cmd.Notify
+	}
+	var notifiers []notify.Notifier
+	for _, spec := range specs {
+		n, err := notify.Parse(spec, webhookConfig(r.Cfg))
+		if err != nil {
+			return ExitFailed, err
+		}
+		notifiers = append(notifiers, n)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	defer r.closeSinks()
+
+	for cycle := 1; ; cycle++ {
+		started := time.Now()
+		// every cycle is summarized on its own
+		r.Report = NewReport()
+		// --deadline bounds every cycle
+		cycleCtx, cancel := r.runContext(ctx)
+		r.ctx = cycleCtx
+		changed := r.watchCycle(cycleCtx, cmd, store, notifiers)
+		cancel()
+		logger.WithFields(map[string]any{
+			"cycle":   cycle,
+			"changed": changed,
+			"took":    time.Since(started).Round(time.Millisecond).String(),
+		}).Info("Watch cycle finished")
+		status := r.writeSummary()
+
+		if cmd.Once {
+			return status, nil
+		}
+
+		wait := cmd.Every
+		if cmd.Jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(cmd.Jitter)))
+		}
+		logger.Infof("Next watch cycle at %s", time.Now().Add(wait).Format(time.RFC3339))
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			logger.Info("Watch stopped")
+			return status, nil
+		}
+	}
+}
+
+// watchCycle checks every target once and returns the number of targets
+// that changed since the previous cycle.
+func (r *Run) watchCycle(ctx context.Context, cmd args.WatchCmd, store *watch.Store, notifiers []notify.Notifier) int {
+	jobs := make(chan string, r.Args.Threads*2)
+	var changed int
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.Args.Threads; i++ {
+		wg.Add(1)
+		go r.runWorker(jobs, &wg, i, func(param, target string) {
+			if r.watchTarget(param, target, cmd, store, notifiers) {
+				mu.Lock()
+				changed++
+				mu.Unlock()
+			}
+		})
+	}
+
+feed:
+	for line := range StreamFileContext(ctx, r.Args.ListFile) {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		select {
+		case jobs <- trimmed:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+	return changed
+}
+
+// watchTarget fetches target, compares it with its last snapshot and
+// notifies about the changes. It reports whether anything changed.
+func (r *Run) watchTarget(param, target string, cmd args.WatchCmd, store *watch.Store, notifiers []notify.Notifier) bool {
+	records, reason, err := r.collectRecords(param, target)
+	if err != nil {
+		// keep the previous snapshot, a failed fetch is not a change
+		logger.Warnf("Client fetch error for %s: %v", target, err)
+		r.targetError(target, err)
+		return false
+	}
+	if reason != "" && reason != TruncatedRecords {
+		// the records not fetched would be reported as removed
+		logger.Warnf("Snapshot of %s is incomplete, the previous one is kept", target)
+		return false
+	}
+
+	previous, ok, err := store.Load(target)
+	if err != nil {
+		logger.Warnf("failed to load snapshot of %s: %v", target, err)
+	}
+
+	if err := store.Save(watch.Snapshot{Target: target, FetchedAt: time.Now().UTC(), Records: records}); err != nil {
+		logger.Warnf("failed to save snapshot of %s: %v", target, err)
+	}
+
+	if !ok {
+		logger.WithFields(map[string]any{
+			"target":  target,
+			"records": len(records),
+		}).Info("Baseline snapshot saved")
+		return false
+	}
+
+	events := diff.Compare(previous.Records, records, diff.Options{Keys: cmd.Keys, Fields: cmd.Fields})
+	if len(events) == 0 {
+		logger.WithField("target", target).Debug("No changes")
+		return false
+	}
+
+	batch := notify.NewBatch(target, events)
+	for _, n := range notifiers {
+		if err := n.Notify(batch); err != nil {
+			logger.Warnf("failed to notify changes of %s: %v", target, err)
+		}
+	}
+	return true
+}
+
+// collectRecords fetches every record of target into memory within the
+// budgets of the run and returns the budget that stopped it, see
+// streamPages. Full mode IPs are fetched with their ASN and geo columns.
+func (r *Run) collectRecords(param, target string) ([]map[string]any, string, error) {
+	r.Report.Begin(param, target)
+
+	if r.Args.ModeFull && param == "ip" {
+		if ip := net.ParseIP(target); ip != nil && ip.To4() == nil {
+			return collectTyped[model.AAAARecord](r, "aaaa", target)
+		}
+		return collectTyped[model.ARecord](r, "a", target)
+	}
+
+	var records []map[string]any
+	var convErr error
+	reason, err := r.fetchRecords(param, target, func(record model.Record) {
+		r.recordFound(target, record.RecordType)
+		m, err := fileutil.ToMap(record)
+		if err != nil {
+			convErr = err
+			return
+		}
+		records = append(records, m)
+	})
+	if err != nil {
+		return nil, reason, err
+	}
+	return records, reason, convErr
+}
+
+// collectTyped fetches the full mode records of ip, see collectRecords.
+func collectTyped[T any](r *Run, recordType, ip string) ([]map[string]any, string, error) {
+	fetch := func(ctx context.Context, pageToken string) (<-chan client.Page[T], <-chan error) {
+		return client.FetchDNSRecordPages[T](ctx, r.Client, recordType, ip, pageToken)
+	}
+	var records []map[string]any
+	var convErr error
+	_, reason, err := streamPages(r, ip, fetch, func(record T) {
+		r.recordFound(ip, recordType)
+		m, err := fileutil.ToMap(record)
+		if err != nil {
+			convErr = err
+			return
+		}
+		records = append(records, m)
+	})
+	if err != nil {
+		return nil, reason, err
+	}
+	return records, reason, convErr
+}
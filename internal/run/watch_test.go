@@ -0,0 +1,65 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/Doom-z/RepClient/cmd/app/args"
+	"github.com/Doom-z/RepClient/internal/notify"
+	"github.com/Doom-z/RepClient/internal/watch"
+	"github.com/Doom-z/RepClient/pkg/diff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingNotifier struct {
+	batches []notify.Batch
+}
+
+func (n *recordingNotifier) Notify(batch notify.Batch) error {
+	n.batches = append(n.batches, batch)
+	return nil
+}
+
+func TestWatchTargetBaselineAndDiff(t *testing.T) {
+	api := &pagingAPI{pages: 2}
+	srv := api.start(t)
+	store, err := watch.NewStore(t.TempDir())
+	require.NoError(t, err)
+	n := &recordingNotifier{}
+	notifiers := []notify.Notifier{n}
+
+	r := newTestRun(t, srv.URL, args.Options{})
+	assert.False(t, r.watchTarget("ip", "1.1.1.1", args.WatchCmd{}, store, notifiers), "the first cycle saves the baseline")
+	baseline, ok, err := store.Load("1.1.1.1")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Len(t, baseline.Records, 4)
+	assert.Empty(t, n.batches)
+
+	assert.False(t, r.watchTarget("ip", "1.1.1.1", args.WatchCmd{}, store, notifiers), "the same records are no change")
+	assert.Empty(t, n.batches)
+
+	api.shift.Store(1)
+	assert.True(t, r.watchTarget("ip", "1.1.1.1", args.WatchCmd{}, store, notifiers))
+	require.Len(t, n.batches, 1)
+	assert.Equal(t, "1.1.1.1", n.batches[0].Target)
+	assert.Equal(t, diff.Stats{Added: 1, Removed: 1}, n.batches[0].Stats)
+}
+
+func TestWatchTargetKeepsTheSnapshotOfAnIncompleteFetch(t *testing.T) {
+	api := &pagingAPI{pages: 2}
+	srv := api.start(t)
+	store, err := watch.NewStore(t.TempDir())
+	require.NoError(t, err)
+	n := &recordingNotifier{}
+
+	r := newTestRun(t, srv.URL, args.Options{})
+	r.watchTarget("ip", "1.1.1.1", args.WatchCmd{}, store, []notify.Notifier{n})
+
+	r = newTestRun(t, srv.URL, args.Options{BudgetFlags: args.BudgetFlags{MaxPages: 1}})
+	assert.False(t, r.watchTarget("ip", "1.1.1.1", args.WatchCmd{}, store, []notify.Notifier{n}))
+	snapshot, _, err := store.Load("1.1.1.1")
+	require.NoError(t, err)
+	assert.Len(t, snapshot.Records, 4, "the records of the second page are not reported as removed")
+	assert.Empty(t, n.batches)
+}
@@ -4,21 +4,13 @@ import (
 	"strings"
 	"sync"
 
-	"github.com/Doom-z/RepClient/pkg/fileutil"
 	"github.com/Doom-z/RepClient/pkg/logger"
 )
 
-func startSaveWorker(wg *sync.WaitGroup, tasks <-chan SaveTask) {
+func (r *Run) startSaveWorker(wg *sync.WaitGroup, tasks <-chan SaveTask) {
 	defer wg.Done()
 	for task := range tasks {
-		switch task.Format {
-		case "txt":
-			if s, ok := task.Data.(string); ok {
-				fileutil.SaveData(s, task.Path, "append")
-			}
-		default:
-			fileutil.SaveData(task.Data, task.Path, "append")
-		}
+		r.emit(task)
 	}
 }
 
@@ -26,11 +18,20 @@ func (r *Run) runWorker(jobs <-chan string, wg *sync.WaitGroup, workerID int, ha
 	defer wg.Done()
 	logger.WithGID().Tracef("Worker %d started", workerID)
 
-	for line := range jobs {
+	for {
+		// with adaptive concurrency only the workers holding a slot take jobs
+		r.concurrency.Acquire()
+		line, ok := <-jobs
+		if !ok {
+			r.concurrency.Release()
+			return
+		}
 		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+		if line != "" {
+			r.trackTarget(workerID, line, func() {
+				r.handleStreamInput(line, handler)
+			})
 		}
-		r.handleStreamInput(line, handler)
+		r.concurrency.Release()
 	}
 }
@@ -0,0 +1,77 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+)
+
+// Snapshot is the last known result set of a target.
+type Snapshot struct {
+	Target    string           `json:"target"`
+	FetchedAt time.Time        `json:"fetched_at"`
+	Records   []map[string]any `json:"records"`
+}
+
+// Store keeps one snapshot file per target inside Dir.
+type Store struct {
+	Dir string
+}
+
+func NewStore(dir string) (*Store, error) {
+	if err := fileutil.EnsureDir(dir); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// Load returns the last snapshot of target, ok is false if there is none yet.
+func (s *Store) Load(target string) (snapshot Snapshot, ok bool, err error) {
+	f, err := os.Open(s.path(target))
+	if errors.Is(err, os.ErrNotExist) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	defer f.Close()
+
+	decoder := json.NewDecoder(f)
+	decoder.UseNumber()
+	if err := decoder.Decode(&snapshot); err != nil {
+		return Snapshot{}, false, err
+	}
+	return snapshot, true, nil
+}
+
+// Save replaces the snapshot of target. The file is written next to the
+// old one and renamed, so an interrupted save keeps the previous snapshot.
+func (s *Store) Save(snapshot Snapshot) error {
+	path := s.path(snapshot.Target)
+	tmp := path + ".tmp"
+	if err := fileutil.SaveData(snapshot, tmp+".json", "overwrite"); err != nil {
+		return err
+	}
+	return os.Rename(tmp+".json", path)
+}
+
+func (s *Store) path(target string) string {
+	return filepath.Join(s.Dir, fileName(target)+".json")
+}
+
+// fileName maps a target to a safe file name, e.g. ipv6 colons are replaced.
+func fileName(target string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, target)
+}
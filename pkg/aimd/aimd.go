@@ -0,0 +1,215 @@
+// Package aimd adapts the number of concurrent workers to the observed
+// request latency, error and throttle rates: additive increase while
+// requests are healthy, multiplicative decrease when they are not.
+package aimd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// Config of a Controller, zero values use the defaults.
+type Config struct {
+	// Min and Max bound the limit, Initial is the limit to start with
+	Min, Max, Initial int
+	// Window is the min number of requests per adjustment, default 10. A
+	// window also spans at least as many requests as the current limit.
+	Window int
+	// LatencyTolerance is how many times slower than the baseline the mean
+	// latency of a window may get before the limit shrinks, default 2
+	LatencyTolerance float64
+	// LatencySlack is how much slower than the baseline a window may always
+	// get, so jitter of fast responses is not taken for overload, default 50ms
+	LatencySlack time.Duration
+	// ErrorRate is the share of failed requests shrinking the limit, default 0.1
+	ErrorRate float64
+	// Backoff multiplies the limit on a decrease, default 0.5
+	Backoff float64
+}
+
+// Stats are the adjustments of a Controller.
+type Stats struct {
+	Limit     int `json:"limit"`
+	Min       int `json:"min"`
+	Max       int `json:"max"`
+	Peak      int `json:"peak"`
+	Requests  int `json:"requests"`
+	Errors    int `json:"errors"`
+	Throttled int `json:"throttled"`
+	Increases int `json:"increases"`
+	Decreases int `json:"decreases"`
+}
+
+// Controller is a semaphore whose size, the limit, follows the outcome of
+// the requests made by its holders. It is safe for concurrent use and a
+// nil Controller does not limit anything.
+type Controller struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	cfg    Config
+	limit  int
+	active int
+	stats  Stats
+
+	// the current window
+	requests, errors, throttled int
+	latency                     time.Duration
+	// baseline is the mean latency of healthy windows
+	baseline time.Duration
+	// sinceDecrease are the requests since the last decrease, throttling
+	// only shrinks the limit again once as many requests as were in flight
+	// under the previous limit, drain, are done
+	sinceDecrease, drain int
+}
+
+// New creates a controller starting at cfg.Initial, clamped to cfg.Min
+// and cfg.Max.
+func New(cfg Config) *Controller {
+	if cfg.Min < 1 {
+		cfg.Min = 1
+	}
+	if cfg.Max < cfg.Min {
+		cfg.Max = cfg.Min
+	}
+	if cfg.Window < 1 {
+		cfg.Window = 10
+	}
+	if cfg.LatencyTolerance <= 1 {
+		cfg.LatencyTolerance = 2
+	}
+	if cfg.LatencySlack <= 0 {
+		cfg.LatencySlack = 50 * time.Millisecond
+	}
+	if cfg.ErrorRate <= 0 {
+		cfg.ErrorRate = 0.1
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		cfg.Backoff = 0.5
+	}
+	c := &Controller{cfg: cfg, limit: min(max(cfg.Initial, cfg.Min), cfg.Max)}
+	c.cond = sync.NewCond(&c.mu)
+	c.stats = Stats{Min: cfg.Min, Max: cfg.Max, Peak: c.limit}
+	return c
+}
+
+// Acquire waits until fewer holders than the limit are active.
+func (c *Controller) Acquire() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.active >= c.limit {
+		c.cond.Wait()
+	}
+	c.active++
+}
+
+// Release ends a hold started with Acquire.
+func (c *Controller) Release() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.cond.Signal()
+}
+
+// Observe records a request that took latency. failed requests are errors
+// of the server or the network, throttled ones were rate limited.
+func (c *Controller) Observe(latency time.Duration, failed, throttled bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stats.Requests++
+	c.requests++
+	c.sinceDecrease++
+	c.latency += latency
+	if failed {
+		c.stats.Errors++
+		c.errors++
+	}
+	if throttled {
+		c.stats.Throttled++
+		c.throttled++
+		if c.sinceDecrease > c.drain {
+			c.decrease("throttled")
+		}
+		return
+	}
+	if c.requests < max(c.cfg.Window, c.limit) {
+		return
+	}
+
+	mean := c.latency / time.Duration(c.requests)
+	errorRate := float64(c.errors) / float64(c.requests)
+	switch {
+	case c.throttled > 0:
+		c.decrease("throttled")
+	case errorRate >= c.cfg.ErrorRate:
+		c.decrease("errors")
+	case c.baseline > 0 && mean > c.baseline+c.cfg.LatencySlack && float64(mean) > c.cfg.LatencyTolerance*float64(c.baseline):
+		c.decrease("latency")
+	default:
+		if c.baseline == 0 || mean < c.baseline {
+			c.baseline = mean
+		} else {
+			// let the baseline follow a slowly changing api
+			c.baseline += (mean - c.baseline) / 10
+		}
+		c.increase()
+	}
+}
+
+func (c *Controller) increase() {
+	c.resetWindow()
+	if c.limit >= c.cfg.Max {
+		return
+	}
+	c.limit++
+	c.stats.Increases++
+	c.stats.Peak = max(c.stats.Peak, c.limit)
+	logger.Debugf("concurrency raised to %d", c.limit)
+	c.cond.Signal()
+}
+
+func (c *Controller) decrease(reason string) {
+	c.resetWindow()
+	c.sinceDecrease, c.drain = 0, c.limit
+	limit := max(int(float64(c.limit)*c.cfg.Backoff), c.cfg.Min)
+	if limit == c.limit {
+		return
+	}
+	c.limit = limit
+	c.stats.Decreases++
+	logger.Debugf("concurrency lowered to %d: %s", c.limit, reason)
+}
+
+func (c *Controller) resetWindow() {
+	c.requests, c.errors, c.throttled, c.latency = 0, 0, 0, 0
+}
+
+// Limit returns the current limit, 0 for a nil Controller.
+func (c *Controller) Limit() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.limit
+}
+
+// Stats returns the current limit and the adjustments so far.
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Limit = c.limit
+	return s
+}
@@ -0,0 +1,103 @@
+package aimd
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func observe(c *Controller, n int, latency time.Duration, failed, throttled bool) {
+	for range n {
+		c.Observe(latency, failed, throttled)
+	}
+}
+
+func TestControllerGrowsWhileHealthy(t *testing.T) {
+	c := New(Config{Min: 1, Max: 4, Initial: 1, Window: 5})
+	observe(c, 5, 10*time.Millisecond, false, false)
+	assert.Equal(t, 2, c.Limit())
+
+	observe(c, 100, 10*time.Millisecond, false, false)
+	assert.Equal(t, 4, c.Limit(), "bounded by max")
+	assert.Equal(t, 4, c.Stats().Peak)
+}
+
+func TestControllerShrinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		latency   time.Duration
+		failed    bool
+		throttled bool
+	}{
+		{name: "throttled", latency: 10 * time.Millisecond, throttled: true},
+		{name: "errors", latency: 10 * time.Millisecond, failed: true},
+		{name: "latency", latency: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(Config{Min: 2, Max: 16, Initial: 8, Window: 8})
+			observe(c, 8, 10*time.Millisecond, false, false)
+			assert.Equal(t, 9, c.Limit())
+
+			observe(c, 9, tt.latency, tt.failed, tt.throttled)
+			assert.Equal(t, 4, c.Limit())
+			assert.Equal(t, 1, c.Stats().Decreases)
+
+			observe(c, 100, tt.latency, tt.failed, tt.throttled)
+			assert.Equal(t, 2, c.Limit(), "bounded by min")
+		})
+	}
+}
+
+func TestControllerIgnoresJitter(t *testing.T) {
+	c := New(Config{Min: 1, Max: 8, Initial: 1, Window: 1})
+	c.Observe(time.Millisecond, false, false)
+	observe(c, 2, 5*time.Millisecond, false, false)
+	assert.Equal(t, 3, c.Limit())
+}
+
+func TestControllerThrottleBurstDecreasesOnce(t *testing.T) {
+	c := New(Config{Min: 1, Max: 16, Initial: 8})
+	// the requests in flight when the first 429 arrives are throttled too
+	observe(c, 9, time.Millisecond, false, true)
+	assert.Equal(t, 4, c.Limit())
+	c.Observe(time.Millisecond, false, true)
+	assert.Equal(t, 2, c.Limit())
+}
+
+func TestControllerLimitsHolders(t *testing.T) {
+	c := New(Config{Min: 1, Max: 3, Initial: 2})
+
+	var active, peak atomic.Int32
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Acquire()
+			defer c.Release()
+			n := active.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(2), peak.Load())
+}
+
+func TestNilController(t *testing.T) {
+	var c *Controller
+	c.Acquire()
+	c.Observe(time.Second, true, true)
+	c.Release()
+	assert.Zero(t, c.Limit())
+}
@@ -0,0 +1,194 @@
+// Package cidr collapses IP addresses into the minimal set of prefixes
+// covering exactly those addresses.
+package cidr
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Formats are the supported export formats.
+var Formats = []string{"list", "csv", "json"}
+
+// Prefix is a collapsed prefix. Members is the number of distinct IPs in
+// it, Records the number of records those IPs were seen in. ASN is 0 when
+// the prefixes are not grouped by ASN or the ASN is unknown.
+type Prefix struct {
+	Prefix  netip.Prefix `json:"prefix"`
+	ASN     int          `json:"asn,omitempty"`
+	Members int          `json:"members"`
+	Records int          `json:"records"`
+}
+
+// Set collects the IPs of records. It is safe for concurrent use.
+type Set struct {
+	mu      sync.Mutex
+	records map[netip.Addr]int
+	asns    map[netip.Addr]int
+}
+
+func NewSet() *Set {
+	return &Set{records: map[netip.Addr]int{}, asns: map[netip.Addr]int{}}
+}
+
+// Add counts a record of ip, asn is 0 when unknown. Invalid IPs are
+// reported as errors.
+func (s *Set) Add(ip string, asn int) error {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return fmt.Errorf("invalid ip %q: %w", ip, err)
+	}
+	addr = addr.Unmap()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[addr]++
+	if asn != 0 {
+		s.asns[addr] = asn
+	}
+	return nil
+}
+
+// Len returns the number of distinct IPs.
+func (s *Set) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.records)
+}
+
+// Prefixes collapses the IPs, IPv4 before IPv6. With byASN the IPs of
+// every ASN are collapsed on their own, ordered by ASN, so a prefix never
+// spans two ASNs.
+func (s *Set) Prefixes(byASN bool) []Prefix {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	groups := map[int][]netip.Addr{}
+	for addr := range s.records {
+		asn := 0
+		if byASN {
+			asn = s.asns[addr]
+		}
+		groups[asn] = append(groups[asn], addr)
+	}
+	asns := make([]int, 0, len(groups))
+	for asn := range groups {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	var prefixes []Prefix
+	for _, asn := range asns {
+		group := groups[asn]
+		sortAddrs(group)
+		// both the prefixes and the addresses are sorted, every address
+		// belongs to the first prefix containing it
+		i := 0
+		for _, pfx := range Collapse(group) {
+			p := Prefix{Prefix: pfx, ASN: asn}
+			for ; i < len(group) && pfx.Contains(group[i]); i++ {
+				p.Members++
+				p.Records += s.records[group[i]]
+			}
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+func sortAddrs(addrs []netip.Addr) {
+	sort.Slice(addrs, func(i, j int) bool { return addrs[i].Less(addrs[j]) })
+}
+
+// Collapse returns the minimal sorted set of prefixes covering exactly addrs.
+func Collapse(addrs []netip.Addr) []netip.Prefix {
+	if len(addrs) == 0 {
+		return nil
+	}
+	sorted := make([]netip.Addr, len(addrs))
+	copy(sorted, addrs)
+	sortAddrs(sorted)
+
+	var prefixes []netip.Prefix
+	start, end := sorted[0], sorted[0]
+	for _, addr := range sorted[1:] {
+		if addr == end {
+			continue
+		}
+		if addr == end.Next() {
+			end = addr
+			continue
+		}
+		prefixes = append(prefixes, rangePrefixes(start, end)...)
+		start, end = addr, addr
+	}
+	return append(prefixes, rangePrefixes(start, end)...)
+}
+
+// rangePrefixes splits the range start-end into the largest aligned prefixes.
+func rangePrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for {
+		var pfx netip.Prefix
+		for bits := 0; bits <= start.BitLen(); bits++ {
+			pfx = netip.PrefixFrom(start, bits).Masked()
+			if pfx.Addr() == start && !end.Less(lastAddr(pfx)) {
+				break
+			}
+		}
+		prefixes = append(prefixes, pfx)
+
+		last := lastAddr(pfx)
+		if last == end {
+			return prefixes
+		}
+		start = last.Next()
+	}
+}
+
+// lastAddr returns the highest address of pfx.
+func lastAddr(pfx netip.Prefix) netip.Addr {
+	b := pfx.Addr().AsSlice()
+	for i := pfx.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - i%8)
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// Write exports prefixes in format, one of Formats.
+func Write(w io.Writer, prefixes []Prefix, format string) error {
+	switch format {
+	case "list":
+		for _, p := range prefixes {
+			if _, err := fmt.Fprintln(w, p.Prefix); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"prefix", "asn", "members", "records"})
+		for _, p := range prefixes {
+			cw.Write([]string{p.Prefix.String(), strconv.Itoa(p.ASN), strconv.Itoa(p.Members), strconv.Itoa(p.Records)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		if prefixes == nil {
+			prefixes = []Prefix{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(prefixes)
+	default:
+		return fmt.Errorf("unsupported cidr format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
@@ -0,0 +1,69 @@
+package cidr
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func addrs(ips ...string) []netip.Addr {
+	var out []netip.Addr
+	for _, ip := range ips {
+		out = append(out, netip.MustParseAddr(ip))
+	}
+	return out
+}
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	var out []string
+	for _, p := range prefixes {
+		out = append(out, p.String())
+	}
+	return out
+}
+
+func TestCollapse(t *testing.T) {
+	assert.Equal(t,
+		[]string{"10.0.0.0/31", "10.0.0.2/32", "10.0.0.5/32", "2001:db8::/127"},
+		prefixStrings(Collapse(addrs("10.0.0.1", "2001:db8::1", "10.0.0.0", "10.0.0.2", "10.0.0.5", "10.0.0.1", "2001:db8::"))))
+
+	var full []string
+	for i := 0; i < 256; i++ {
+		full = append(full, netip.AddrFrom4([4]byte{192, 0, 2, byte(i)}).String())
+	}
+	assert.Equal(t, []string{"192.0.2.0/24"}, prefixStrings(Collapse(addrs(full...))))
+
+	// 1-6 is not aligned: 1/32, 2/31, 4/31, 6/32
+	assert.Equal(t,
+		[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+		prefixStrings(Collapse(addrs("10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4", "10.0.0.5", "10.0.0.6"))))
+
+	assert.Equal(t, []string{"255.255.255.254/31"}, prefixStrings(Collapse(addrs("255.255.255.255", "255.255.255.254"))))
+	assert.Nil(t, Collapse(nil))
+}
+
+func TestSetPrefixesByASN(t *testing.T) {
+	s := NewSet()
+	assert.NoError(t, s.Add("10.0.0.0", 64500))
+	assert.NoError(t, s.Add("10.0.0.0", 64500))
+	assert.NoError(t, s.Add("10.0.0.1", 64501))
+	assert.NoError(t, s.Add("::ffff:10.0.0.2", 0))
+	assert.Error(t, s.Add("not-an-ip", 0))
+	assert.Equal(t, 3, s.Len())
+
+	assert.Equal(t, []Prefix{
+		{Prefix: netip.MustParsePrefix("10.0.0.0/31"), Members: 2, Records: 3},
+		{Prefix: netip.MustParsePrefix("10.0.0.2/32"), Members: 1, Records: 1},
+	}, s.Prefixes(false))
+
+	byASN := s.Prefixes(true)
+	assert.Len(t, byASN, 3)
+	assert.Equal(t, Prefix{Prefix: netip.MustParsePrefix("10.0.0.0/32"), ASN: 64500, Members: 1, Records: 2}, byASN[1])
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, byASN, "csv"))
+	assert.Equal(t, "prefix,asn,members,records\n10.0.0.2/32,0,1,1\n10.0.0.0/32,64500,1,2\n10.0.0.1/32,64501,1,1\n", buf.String())
+	assert.Error(t, Write(&buf, byASN, "xml"))
+}
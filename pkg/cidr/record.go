@@ -0,0 +1,20 @@
+package cidr
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// AddRecord counts the ip and asn fields of a record in the map form of
+// fileutil.LoadRecords. Records without an ip are skipped.
+func (s *Set) AddRecord(record map[string]any) error {
+	ip, ok := record["ip"]
+	if !ok || ip == nil || fmt.Sprint(ip) == "" {
+		return nil
+	}
+	asn := 0
+	if v, ok := record["asn"]; ok && v != nil {
+		asn, _ = strconv.Atoi(fmt.Sprint(v))
+	}
+	return s.Add(fmt.Sprint(ip), asn)
+}
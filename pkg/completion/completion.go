@@ -0,0 +1,271 @@
+// Package completion generates bash, zsh and fish completion scripts from
+// go-arg command structs.
+package completion
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Command is a command with its flags and subcommands.
+type Command struct {
+	Name        string
+	Help        string
+	Flags       []Flag
+	Subcommands []*Command
+}
+
+type Flag struct {
+	Long  string
+	Short string
+	Help  string
+	// Value is set for flags taking a value
+	Value bool
+}
+
+// FromStruct builds the command tree of dest, a go-arg destination struct
+// or a pointer to one.
+func FromStruct(name string, dest any) *Command {
+	t := reflect.TypeOf(dest)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	cmd := &Command{Name: name}
+	walk(cmd, t)
+	return cmd
+}
+
+func walk(cmd *Command, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("arg")
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			walk(cmd, field.Type)
+			continue
+		}
+
+		flag := Flag{Help: field.Tag.Get("help"), Value: field.Type.Kind() != reflect.Bool}
+		positional := false
+		for _, part := range strings.Split(tag, ",") {
+			switch {
+			case strings.HasPrefix(part, "subcommand"):
+				name := strings.TrimPrefix(strings.TrimPrefix(part, "subcommand"), ":")
+				if name == "" {
+					name = strings.ToLower(field.Name)
+				}
+				sub := &Command{Name: name, Help: flag.Help}
+				walk(sub, field.Type.Elem())
+				cmd.Subcommands = append(cmd.Subcommands, sub)
+				positional = true
+			case part == "positional":
+				positional = true
+			case strings.HasPrefix(part, "--"):
+				flag.Long = part
+			case strings.HasPrefix(part, "-"):
+				flag.Short = part
+			}
+		}
+		if positional {
+			continue
+		}
+		if flag.Long == "" {
+			flag.Long = "--" + strings.ToLower(field.Name)
+		}
+		cmd.Flags = append(cmd.Flags, flag)
+	}
+}
+
+// Write writes the completion script of cmd for shell.
+func Write(w io.Writer, cmd *Command, shell string) error {
+	switch shell {
+	case "bash":
+		return writeBash(w, cmd)
+	case "zsh":
+		return writeZsh(w, cmd)
+	case "fish":
+		return writeFish(w, cmd)
+	default:
+		return fmt.Errorf("unsupported shell %q, expected one of %s", shell, strings.Join(Shells, ", "))
+	}
+}
+
+// path is a command with the names leading to it and the flags it accepts,
+// go-arg accepts the flags of parent commands after a subcommand.
+type path struct {
+	names []string
+	cmd   *Command
+	flags []Flag
+}
+
+func paths(cmd *Command) []path {
+	var out []path
+	var visit func(names []string, cmd *Command, inherited []Flag)
+	visit = func(names []string, cmd *Command, inherited []Flag) {
+		flags := append(append([]Flag{}, inherited...), cmd.Flags...)
+		out = append(out, path{names: names, cmd: cmd, flags: flags})
+		for _, sub := range cmd.Subcommands {
+			visit(append(append([]string{}, names...), sub.Name), sub, flags)
+		}
+	}
+	visit([]string{cmd.Name}, cmd, nil)
+	return out
+}
+
+func funcName(cmd *Command) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(cmd.Name)
+}
+
+func writeBash(w io.Writer, cmd *Command) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", cmd.Name)
+	fmt.Fprintf(&b, "%s() {\n", funcName(cmd))
+	fmt.Fprintf(&b, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" cmd=%q word i\n", cmd.Name)
+	b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+	b.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+	b.WriteString("        case \"$cmd $word\" in\n")
+	var subs []string
+	for _, p := range paths(cmd)[1:] {
+		subs = append(subs, fmt.Sprintf("%q", strings.Join(p.names, " ")))
+	}
+	if len(subs) > 0 {
+		fmt.Fprintf(&b, "            %s) cmd=\"$cmd $word\" ;;\n", strings.Join(subs, "|"))
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    local opts=\"\"\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, p := range paths(cmd) {
+		var words []string
+		for _, sub := range p.cmd.Subcommands {
+			words = append(words, sub.Name)
+		}
+		for _, f := range p.flags {
+			words = append(words, f.Long)
+			if f.Short != "" {
+				words = append(words, f.Short)
+			}
+		}
+		fmt.Fprintf(&b, "        %q) opts=%q ;;\n", strings.Join(p.names, " "), strings.Join(words, " "))
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    COMPREPLY=($(compgen -W \"$opts\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", funcName(cmd), cmd.Name)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// zshQuote escapes a "name:description" entry of _describe.
+func zshQuote(name, help string) string {
+	entry := strings.ReplaceAll(name, ":", `\:`) + ":" + help
+	return "'" + strings.ReplaceAll(entry, "'", `'\''`) + "'"
+}
+
+func writeZsh(w io.Writer, cmd *Command) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n", cmd.Name)
+	fmt.Fprintf(&b, "%s() {\n", funcName(cmd))
+	fmt.Fprintf(&b, "    local cmd=%q word i\n", cmd.Name)
+	b.WriteString("    local -a commands options\n")
+	b.WriteString("    for ((i = 2; i < CURRENT; i++)); do\n")
+	b.WriteString("        word=${words[i]}\n")
+	b.WriteString("        case \"$cmd $word\" in\n")
+	var subs []string
+	for _, p := range paths(cmd)[1:] {
+		subs = append(subs, fmt.Sprintf("%q", strings.Join(p.names, " ")))
+	}
+	if len(subs) > 0 {
+		fmt.Fprintf(&b, "            %s) cmd=\"$cmd $word\" ;;\n", strings.Join(subs, "|"))
+	}
+	b.WriteString("        esac\n")
+	b.WriteString("    done\n")
+	b.WriteString("    case \"$cmd\" in\n")
+	for _, p := range paths(cmd) {
+		fmt.Fprintf(&b, "        %q)\n", strings.Join(p.names, " "))
+		b.WriteString("            commands=(")
+		for _, sub := range p.cmd.Subcommands {
+			b.WriteString(" " + zshQuote(sub.Name, sub.Help))
+		}
+		b.WriteString(" )\n")
+		b.WriteString("            options=(")
+		for _, f := range p.flags {
+			b.WriteString(" " + zshQuote(f.Long, f.Help))
+			if f.Short != "" {
+				b.WriteString(" " + zshQuote(f.Short, f.Help))
+			}
+		}
+		b.WriteString(" )\n")
+		b.WriteString("            ;;\n")
+	}
+	b.WriteString("    esac\n")
+	b.WriteString("    if [[ ${words[CURRENT]} == -* ]]; then\n")
+	b.WriteString("        _describe -t options 'option' options\n")
+	b.WriteString("    elif (( ${#commands} )); then\n")
+	b.WriteString("        _describe -t commands 'command' commands\n")
+	b.WriteString("    else\n")
+	b.WriteString("        _files\n")
+	b.WriteString("    fi\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "compdef %s %s\n", funcName(cmd), cmd.Name)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func writeFish(w io.Writer, cmd *Command) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", cmd.Name)
+	for _, p := range paths(cmd) {
+		// the names after the root must have been seen, and no subcommand of p yet
+		var conditions []string
+		if len(p.names) == 1 {
+			conditions = append(conditions, "__fish_use_subcommand")
+		}
+		for _, name := range p.names[1:] {
+			conditions = append(conditions, "__fish_seen_subcommand_from "+name)
+		}
+		children := make([]string, len(p.cmd.Subcommands))
+		for i, sub := range p.cmd.Subcommands {
+			children[i] = sub.Name
+		}
+		if len(p.names) > 1 && len(children) > 0 {
+			conditions = append(conditions, "not __fish_seen_subcommand_from "+strings.Join(children, " "))
+		}
+		condition := fishQuote(strings.Join(conditions, "; and "))
+
+		for _, sub := range p.cmd.Subcommands {
+			fmt.Fprintf(&b, "complete -c %s -n %s -f -a %s -d %s\n", cmd.Name, condition, sub.Name, fishQuote(sub.Help))
+		}
+		// root flags apply everywhere, the flags of a subcommand once it is given
+		flagCondition := ""
+		if len(p.names) > 1 {
+			parts := make([]string, 0, len(p.names)-1)
+			for _, name := range p.names[1:] {
+				parts = append(parts, "__fish_seen_subcommand_from "+name)
+			}
+			flagCondition = " -n " + fishQuote(strings.Join(parts, "; and "))
+		}
+		for _, f := range p.cmd.Flags {
+			fmt.Fprintf(&b, "complete -c %s%s -l %s", cmd.Name, flagCondition, strings.TrimPrefix(f.Long, "--"))
+			if f.Short != "" {
+				fmt.Fprintf(&b, " -s %s", strings.TrimPrefix(f.Short, "-"))
+			}
+			if f.Value {
+				b.WriteString(" -r")
+			}
+			fmt.Fprintf(&b, " -d %s\n", fishQuote(f.Help))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,77 @@
+package completion
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Shared struct {
+	Max int `arg:"-m,--max" help:"max records"`
+}
+
+type queryCmd struct {
+	Ipv4 string `arg:"-i,--ipv4" help:"ipv4 address"`
+	Shared
+}
+
+type showCmd struct{}
+
+type configCmd struct {
+	Show *showCmd `arg:"subcommand:show" help:"print the config"`
+}
+
+type testArgs struct {
+	Query     *queryCmd  `arg:"subcommand:query" help:"query one target"`
+	ConfigCmd *configCmd `arg:"subcommand:config" help:"config: show or init"`
+	Paths     []string   `arg:"positional"`
+	Verbose   bool       `arg:"-v,--verbose" help:"verbose output"`
+	NoColor   bool       `help:"no colors"`
+}
+
+func TestFromStruct(t *testing.T) {
+	cmd := FromStruct("rc", &testArgs{})
+	assert.Equal(t, []Flag{
+		{Long: "--verbose", Short: "-v", Help: "verbose output"},
+		{Long: "--nocolor", Help: "no colors"},
+	}, cmd.Flags)
+	require.Len(t, cmd.Subcommands, 2)
+
+	query := cmd.Subcommands[0]
+	assert.Equal(t, "query", query.Name)
+	assert.Equal(t, []Flag{
+		{Long: "--ipv4", Short: "-i", Help: "ipv4 address", Value: true},
+		{Long: "--max", Short: "-m", Help: "max records", Value: true},
+	}, query.Flags)
+
+	config := cmd.Subcommands[1]
+	require.Len(t, config.Subcommands, 1)
+	assert.Equal(t, "show", config.Subcommands[0].Name)
+}
+
+func TestWrite(t *testing.T) {
+	cmd := FromStruct("rc", &testArgs{})
+
+	var bash bytes.Buffer
+	require.NoError(t, Write(&bash, cmd, "bash"))
+	assert.Contains(t, bash.String(), `"rc query"|"rc config"|"rc config show") cmd="$cmd $word" ;;`)
+	assert.Contains(t, bash.String(), `"rc") opts="query config --verbose -v --nocolor" ;;`)
+	assert.Contains(t, bash.String(), `"rc query") opts="--verbose -v --nocolor --ipv4 -i --max -m" ;;`)
+	assert.Contains(t, bash.String(), "complete -o default -F _rc rc\n")
+
+	var zsh bytes.Buffer
+	require.NoError(t, Write(&zsh, cmd, "zsh"))
+	assert.Contains(t, zsh.String(), `commands=( 'query:query one target' 'config:config: show or init' )`)
+	assert.Contains(t, zsh.String(), "compdef _rc rc\n")
+
+	var fish bytes.Buffer
+	require.NoError(t, Write(&fish, cmd, "fish"))
+	assert.Contains(t, fish.String(), "complete -c rc -n '__fish_use_subcommand' -f -a query -d 'query one target'\n")
+	assert.Contains(t, fish.String(), "complete -c rc -n '__fish_seen_subcommand_from config; and not __fish_seen_subcommand_from show' -f -a show -d 'print the config'\n")
+	assert.Contains(t, fish.String(), "complete -c rc -n '__fish_seen_subcommand_from query' -l ipv4 -s i -r -d 'ipv4 address'\n")
+	assert.Contains(t, fish.String(), "complete -c rc -l verbose -s v -d 'verbose output'\n")
+
+	assert.Error(t, Write(&bash, cmd, "powershell"))
+}
@@ -0,0 +1,203 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type EventType string
+
+const (
+	Added   EventType = "added"
+	Removed EventType = "removed"
+	Changed EventType = "changed"
+)
+
+var (
+	// DefaultKeys identify the same record across two result sets.
+	DefaultKeys = []string{"domain_id", "ip"}
+	// DefaultFields are compared for records matched by key.
+	DefaultFields = []string{"asn", "country", "city"}
+)
+
+type Change struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type Event struct {
+	Type    EventType         `json:"type"`
+	Key     map[string]string `json:"key"`
+	Old     map[string]any    `json:"old,omitempty"`
+	New     map[string]any    `json:"new,omitempty"`
+	Changes map[string]Change `json:"changes,omitempty"`
+}
+
+type Options struct {
+	// Keys are the fields used to match records, DefaultKeys when empty.
+	Keys []string
+	// Fields are compared on matched records, DefaultFields when empty.
+	Fields []string
+}
+
+type Stats struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+// Compare returns the records added, removed and changed between old and new.
+// A field only counts as changed when it is present in both records, so a
+// lightweight result compared with a full mode one does not report changes.
+// When a key occurs more than once in a set the last record wins.
+func Compare(old, new []map[string]any, opts Options) []Event {
+	keys := opts.Keys
+	if len(keys) == 0 {
+		keys = DefaultKeys
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+
+	oldByKey := index(old, keys)
+	newByKey := index(new, keys)
+
+	var events []Event
+	for id, n := range newByKey {
+		o, ok := oldByKey[id]
+		if !ok {
+			events = append(events, Event{Type: Added, Key: keyOf(n, keys), New: n})
+			continue
+		}
+		if changes := compareFields(o, n, fields); len(changes) > 0 {
+			events = append(events, Event{Type: Changed, Key: keyOf(n, keys), Old: o, New: n, Changes: changes})
+		}
+	}
+	for id, o := range oldByKey {
+		if _, ok := newByKey[id]; !ok {
+			events = append(events, Event{Type: Removed, Key: keyOf(o, keys), Old: o})
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].Type != events[j].Type {
+			return events[i].Type < events[j].Type
+		}
+		return keyID(events[i].Key, keys) < keyID(events[j].Key, keys)
+	})
+	return events
+}
+
+// Summarize counts events per type.
+func Summarize(events []Event) Stats {
+	var s Stats
+	for _, e := range events {
+		switch e.Type {
+		case Added:
+			s.Added++
+		case Removed:
+			s.Removed++
+		case Changed:
+			s.Changed++
+		}
+	}
+	return s
+}
+
+// WriteSummary writes a human readable report of events to w.
+func WriteSummary(w io.Writer, events []Event) error {
+	s := Summarize(events)
+	if _, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", s.Added, s.Removed, s.Changed); err != nil {
+		return err
+	}
+
+	for _, e := range events {
+		var line string
+		switch e.Type {
+		case Added:
+			line = "+ " + formatKey(e.Key)
+		case Removed:
+			line = "- " + formatKey(e.Key)
+		case Changed:
+			names := make([]string, 0, len(e.Changes))
+			for name := range e.Changes {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			parts := make([]string, 0, len(names))
+			for _, name := range names {
+				c := e.Changes[name]
+				parts = append(parts, fmt.Sprintf("%s: %v -> %v", name, c.Old, c.New))
+			}
+			line = "~ " + formatKey(e.Key) + " (" + strings.Join(parts, ", ") + ")"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func index(records []map[string]any, keys []string) map[string]map[string]any {
+	out := make(map[string]map[string]any, len(records))
+	for _, record := range records {
+		out[keyID(keyOf(record, keys), keys)] = record
+	}
+	return out
+}
+
+func keyOf(record map[string]any, keys []string) map[string]string {
+	key := make(map[string]string, len(keys))
+	for _, k := range keys {
+		key[k] = stringify(record[k])
+	}
+	return key
+}
+
+func keyID(key map[string]string, keys []string) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = key[k]
+	}
+	return strings.Join(parts, "\x00")
+}
+
+func formatKey(key map[string]string) string {
+	names := make([]string, 0, len(key))
+	for name := range key {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, name+"="+key[name])
+	}
+	return strings.Join(parts, " ")
+}
+
+func compareFields(old, new map[string]any, fields []string) map[string]Change {
+	changes := map[string]Change{}
+	for _, field := range fields {
+		o, okOld := old[field]
+		n, okNew := new[field]
+		if !okOld || !okNew {
+			continue
+		}
+		if stringify(o) != stringify(n) {
+			changes[field] = Change{Old: o, New: n}
+		}
+	}
+	return changes
+}
+
+// stringify compares values loaded from different formats, e.g. the json
+// number 13335 and the csv string "13335" are equal.
+func stringify(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,65 @@
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	old := []map[string]any{
+		{"domain_id": "a.com", "ip": "1.1.1.1", "asn": json.Number("13335"), "country": "US"},
+		{"domain_id": "b.com", "ip": "1.1.1.1", "asn": json.Number("13335"), "country": "US"},
+		{"domain_id": "c.com", "ip": "1.1.1.1"},
+	}
+	new := []map[string]any{
+		{"domain_id": "a.com", "ip": "1.1.1.1", "asn": "13335", "country": "US"},
+		{"domain_id": "b.com", "ip": "1.1.1.1", "asn": json.Number("15169"), "country": "US"},
+		{"domain_id": "d.com", "ip": "1.1.1.1"},
+	}
+
+	events := Compare(old, new, Options{})
+	assert.Len(t, events, 3)
+
+	assert.Equal(t, Added, events[0].Type)
+	assert.Equal(t, "d.com", events[0].Key["domain_id"])
+
+	assert.Equal(t, Changed, events[1].Type)
+	assert.Equal(t, "b.com", events[1].Key["domain_id"])
+	assert.Contains(t, events[1].Changes, "asn")
+	assert.NotContains(t, events[1].Changes, "country")
+
+	assert.Equal(t, Removed, events[2].Type)
+	assert.Equal(t, "c.com", events[2].Key["domain_id"])
+
+	assert.Equal(t, Stats{Added: 1, Removed: 1, Changed: 1}, Summarize(events))
+}
+
+func TestCompareMissingFieldIsNotAChange(t *testing.T) {
+	old := []map[string]any{{"domain_id": "a.com", "ip": "1.1.1.1"}}
+	new := []map[string]any{{"domain_id": "a.com", "ip": "1.1.1.1", "asn": 13335}}
+
+	assert.Empty(t, Compare(old, new, Options{}))
+}
+
+func TestCompareCustomKeys(t *testing.T) {
+	old := []map[string]any{{"domain_id": "a.com", "ip": "1.1.1.1"}}
+	new := []map[string]any{{"domain_id": "a.com", "ip": "2.2.2.2"}}
+
+	assert.Empty(t, Compare(old, new, Options{Keys: []string{"domain_id"}, Fields: []string{"city"}}))
+	assert.Len(t, Compare(old, new, Options{Keys: []string{"domain_id"}, Fields: []string{"ip"}}), 1)
+}
+
+func TestWriteSummary(t *testing.T) {
+	events := Compare(
+		[]map[string]any{{"domain_id": "a.com", "ip": "1.1.1.1", "city": "Paris"}},
+		[]map[string]any{{"domain_id": "a.com", "ip": "1.1.1.1", "city": "Lyon"}},
+		Options{},
+	)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSummary(&buf, events))
+	assert.Equal(t, "0 added, 0 removed, 1 changed\n~ domain_id=a.com ip=1.1.1.1 (city: Paris -> Lyon)\n", buf.String())
+}
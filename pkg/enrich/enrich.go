@@ -0,0 +1,126 @@
+// Package enrich looks up the ASN and location of IPs in local databases:
+// MaxMind format .mmdb files (GeoLite2/GeoIP2 City, Country and ASN) and
+// ip2asn TSV files (https://iptoasn.com).
+package enrich
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+// Info is what the databases know about an IP. Empty fields are unknown.
+type Info struct {
+	ASN     int
+	ASNName string
+	Country string
+	City    string
+}
+
+func (i Info) empty() bool {
+	return i == Info{}
+}
+
+// complete reports whether every field of i is known.
+func (i Info) complete() bool {
+	return i.ASN != 0 && i.ASNName != "" && i.Country != "" && i.City != ""
+}
+
+// merge fills the unknown fields of i from other.
+func (i *Info) merge(other Info) {
+	if i.ASN == 0 {
+		i.ASN = other.ASN
+	}
+	if i.ASNName == "" {
+		i.ASNName = other.ASNName
+	}
+	if i.Country == "" {
+		i.Country = other.Country
+	}
+	if i.City == "" {
+		i.City = other.City
+	}
+}
+
+// Source is a database of IP information.
+type Source interface {
+	Lookup(ip netip.Addr) (Info, bool, error)
+	Close() error
+}
+
+type Config struct {
+	MMDB   []string
+	IP2ASN []string
+}
+
+// Enricher looks an IP up in every source, earlier sources win when they
+// disagree. It is safe for concurrent use.
+type Enricher struct {
+	sources []Source
+}
+
+// New opens every database of cfg. It returns nil when none is configured.
+func New(cfg Config) (*Enricher, error) {
+	e := &Enricher{}
+	for _, path := range cfg.MMDB {
+		source, err := OpenMMDB(path)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		e.sources = append(e.sources, source)
+	}
+	for _, path := range cfg.IP2ASN {
+		source, err := OpenIP2ASN(path)
+		if err != nil {
+			e.Close()
+			return nil, err
+		}
+		e.sources = append(e.sources, source)
+	}
+	if len(e.sources) == 0 {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// NewWithSources creates an enricher over already opened sources.
+func NewWithSources(sources ...Source) *Enricher {
+	return &Enricher{sources: sources}
+}
+
+// Lookup returns the merged information of the sources about ip and
+// whether any source knew it. Every field comes from the first source
+// knowing it, the sources after are not asked once every field is known.
+func (e *Enricher) Lookup(ip string) (Info, bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return Info{}, false, fmt.Errorf("invalid ip %q: %w", ip, err)
+	}
+	addr = addr.Unmap()
+
+	var info Info
+	var errs []error
+	for _, source := range e.sources {
+		found, ok, err := source.Lookup(addr)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			info.merge(found)
+		}
+		if info.complete() {
+			break
+		}
+	}
+	return info, !info.empty(), errors.Join(errs...)
+}
+
+func (e *Enricher) Close() error {
+	var errs []error
+	for _, source := range e.sources {
+		errs = append(errs, source.Close())
+	}
+	return errors.Join(errs...)
+}
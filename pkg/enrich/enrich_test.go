@@ -0,0 +1,94 @@
+package enrich
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ip2asnTSV = `1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+1.0.1.0	1.0.3.255	0	None	Not routed
+8.8.8.0	8.8.8.255	15169	US	GOOGLE
+2606:4700::	2606:4700:ffff:ffff:ffff:ffff:ffff:ffff	13335	US	CLOUDFLARENET
+`
+
+func writeTSV(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "ip2asn.tsv")
+	assert.NoError(t, os.WriteFile(path, []byte(ip2asnTSV), 0o644))
+	return path
+}
+
+func TestIP2ASNLookup(t *testing.T) {
+	db, err := OpenIP2ASN(writeTSV(t))
+	assert.NoError(t, err)
+
+	info, ok, err := db.Lookup(netip.MustParseAddr("8.8.8.8"))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Info{ASN: 15169, ASNName: "GOOGLE", Country: "US"}, info)
+
+	_, ok, _ = db.Lookup(netip.MustParseAddr("1.0.2.1"))
+	assert.False(t, ok, "not routed ranges are skipped")
+	_, ok, _ = db.Lookup(netip.MustParseAddr("0.0.0.1"))
+	assert.False(t, ok)
+
+	info, ok, _ = db.Lookup(netip.MustParseAddr("2606:4700::1111"))
+	assert.True(t, ok)
+	assert.Equal(t, 13335, info.ASN)
+}
+
+type staticSource Info
+
+func (s staticSource) Lookup(netip.Addr) (Info, bool, error) { return Info(s), true, nil }
+func (s staticSource) Close() error                          { return nil }
+
+func TestEnricherMergesSources(t *testing.T) {
+	db, err := OpenIP2ASN(writeTSV(t))
+	assert.NoError(t, err)
+	e := NewWithSources(staticSource{Country: "DE", City: "Berlin"}, db)
+
+	info, ok, err := e.Lookup("::ffff:8.8.8.8")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Info{ASN: 15169, ASNName: "GOOGLE", Country: "DE", City: "Berlin"}, info)
+
+	_, _, err = e.Lookup("not-an-ip")
+	assert.Error(t, err)
+}
+
+// countingSource is a staticSource counting its lookups.
+type countingSource struct {
+	staticSource
+	lookups int
+}
+
+func (s *countingSource) Lookup(addr netip.Addr) (Info, bool, error) {
+	s.lookups++
+	return s.staticSource.Lookup(addr)
+}
+
+func TestEnricherStopsOnceComplete(t *testing.T) {
+	first := &countingSource{staticSource: staticSource{ASN: 13335, ASNName: "CLOUDFLARENET", Country: "US"}}
+	second := &countingSource{staticSource: staticSource{ASN: 1, Country: "DE", City: "Berlin"}}
+	third := &countingSource{staticSource: staticSource{City: "Frankfurt"}}
+	e := NewWithSources(first, second, third)
+
+	info, ok, err := e.Lookup("1.1.1.1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, Info{ASN: 13335, ASNName: "CLOUDFLARENET", Country: "US", City: "Berlin"}, info, "the first hit of every field wins")
+	assert.Equal(t, 1, second.lookups)
+	assert.Zero(t, third.lookups, "no source is asked once every field is known")
+}
+
+func TestNewWithoutDatabases(t *testing.T) {
+	e, err := New(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, e)
+
+	_, err = New(Config{MMDB: []string{"missing.mmdb"}})
+	assert.Error(t, err)
+}
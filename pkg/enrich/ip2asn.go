@@ -0,0 +1,100 @@
+package enrich
+
+import (
+	"bufio"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ipRange struct {
+	start, end netip.Addr
+	info       Info
+}
+
+// IP2ASN is an ip2asn TSV database loaded into memory. Every line is
+// range_start, range_end, AS_number, country_code and AS_description;
+// ranges with AS number 0 are not routed and skipped.
+type IP2ASN struct {
+	ranges []ipRange
+}
+
+func OpenIP2ASN(path string) (*IP2ASN, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ip2asn %s: %w", path, err)
+	}
+	defer f.Close()
+
+	db := &IP2ASN{}
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		r, err := parseIP2ASNLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if r.info.ASN != 0 {
+			db.ranges = append(db.ranges, r)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ip2asn %s: %w", path, err)
+	}
+
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return db.ranges[i].start.Less(db.ranges[j].start)
+	})
+	return db, nil
+}
+
+func parseIP2ASNLine(line string) (ipRange, error) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 3 {
+		return ipRange{}, fmt.Errorf("expected at least 3 tab separated fields, got %d", len(fields))
+	}
+	start, err := netip.ParseAddr(fields[0])
+	if err != nil {
+		return ipRange{}, err
+	}
+	end, err := netip.ParseAddr(fields[1])
+	if err != nil {
+		return ipRange{}, err
+	}
+	asn, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid AS number %q", fields[2])
+	}
+
+	r := ipRange{start: start.Unmap(), end: end.Unmap(), info: Info{ASN: asn}}
+	if len(fields) > 3 && fields[3] != "None" {
+		r.info.Country = fields[3]
+	}
+	if len(fields) > 4 && fields[4] != "Not routed" {
+		r.info.ASNName = fields[4]
+	}
+	return r, nil
+}
+
+func (db *IP2ASN) Lookup(ip netip.Addr) (Info, bool, error) {
+	// the last range starting at or before ip
+	i := sort.Search(len(db.ranges), func(i int) bool {
+		return ip.Less(db.ranges[i].start)
+	}) - 1
+	if i < 0 || db.ranges[i].end.Less(ip) {
+		return Info{}, false, nil
+	}
+	return db.ranges[i].info, true, nil
+}
+
+func (db *IP2ASN) Close() error {
+	return nil
+}
@@ -0,0 +1,51 @@
+package enrich
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbRecord covers the fields used from the City, Country and ASN databases.
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	ASN     uint   `maxminddb:"autonomous_system_number"`
+	ASNName string `maxminddb:"autonomous_system_organization"`
+}
+
+// MMDB is a MaxMind format database.
+type MMDB struct {
+	reader *maxminddb.Reader
+}
+
+func OpenMMDB(path string) (*MMDB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mmdb %s: %w", path, err)
+	}
+	return &MMDB{reader: reader}, nil
+}
+
+func (m *MMDB) Lookup(ip netip.Addr) (Info, bool, error) {
+	var record mmdbRecord
+	_, ok, err := m.reader.LookupNetwork(ip.AsSlice(), &record)
+	if err != nil || !ok {
+		return Info{}, false, err
+	}
+	return Info{
+		ASN:     int(record.ASN),
+		ASNName: record.ASNName,
+		Country: record.Country.ISOCode,
+		City:    record.City.Names["en"],
+	}, true, nil
+}
+
+func (m *MMDB) Close() error {
+	return m.reader.Close()
+}
@@ -0,0 +1,171 @@
+package fileutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// SupportedLoadExt lists the extensions LoadRecords can read back.
+var SupportedLoadExt = []string{".ndjson", ".json", ".csv", ".txt"}
+
+// LoadRecords reads records written by SaveData from a file or from every
+// supported file inside a directory and its subdirectories, such as the
+// per group directories of --group-by.
+// Each record is returned as a generic map so lightweight and full mode
+// results can be read the same way. txt lines become {"domain_id": line}.
+func LoadRecords(path string) ([]map[string]any, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return loadRecordsFile(path)
+	}
+
+	// WalkDir visits files in lexical order
+	var files []string
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && isSupportedLoadExt(entry.Name()) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []map[string]any
+	for _, file := range files {
+		loaded, err := loadRecordsFile(file)
+		if err != nil {
+			// directories may hold non record files such as the run summary
+			logger.Warnf("skipping %s: %v", file, err)
+			continue
+		}
+		records = append(records, loaded...)
+	}
+	return records, nil
+}
+
+func isSupportedLoadExt(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, supported := range SupportedLoadExt {
+		if ext == supported {
+			return true
+		}
+	}
+	return false
+}
+
+func loadRecordsFile(path string) ([]map[string]any, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".ndjson":
+		return loadNDJSON(f)
+	case ".json":
+		return loadJSON(f)
+	case ".csv":
+		return loadCSV(f)
+	case ".txt":
+		return loadTxt(f)
+	default:
+		return nil, fmt.Errorf("unsupported file format: %s in file %s", ext, path)
+	}
+}
+
+func loadNDJSON(r io.Reader) ([]map[string]any, error) {
+	var records []map[string]any
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				return records, nil
+			}
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+func loadJSON(r io.Reader) ([]map[string]any, error) {
+	var records []map[string]any
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&records); err != nil {
+		return nil, fmt.Errorf("expected a json array of records: %w", err)
+	}
+	return records, nil
+}
+
+func loadCSV(r io.Reader) ([]map[string]any, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	records := make([]map[string]any, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		record := make(map[string]any, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				record[name] = row[i]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func loadTxt(r io.Reader) ([]map[string]any, error) {
+	var records []map[string]any
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		records = append(records, map[string]any{"domain_id": line})
+	}
+	return records, scanner.Err()
+}
+
+// ToMap converts a record struct into the generic map form returned by
+// LoadRecords, using its json field names.
+func ToMap(v any) (map[string]any, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
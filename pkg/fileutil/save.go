@@ -1,12 +1,16 @@
 package fileutil
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
 )
 
@@ -32,6 +36,9 @@ func SaveData(data any, outputFile string, mode string) error {
 
 		return saveAsTxt(data, outputFile)
 	case ".csv":
+		if mode == "append" {
+			return appendAsCSV(data, outputFile)
+		}
 		return saveAsCSV(data, outputFile)
 	default:
 		return fmt.Errorf("unsupported file format: %s in file %s", ext, outputFile)
@@ -234,9 +241,181 @@ func saveAsCSV(data any, path string) error {
 			}
 			writer.Write(line)
 		}
+	case []map[string]any:
+		// records can miss fields, the header is the sorted union of all keys
+		seen := make(map[string]bool)
+		var header []string
+		for _, row := range records {
+			for k := range row {
+				if !seen[k] {
+					seen[k] = true
+					header = append(header, k)
+				}
+			}
+		}
+		sort.Strings(header)
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+		for _, row := range records {
+			line := make([]string, len(header))
+			for i, h := range header {
+				line[i] = csvCell(row[h])
+			}
+			if err := writer.Write(line); err != nil {
+				return err
+			}
+		}
 	default:
-		return errors.New("unsupported type for .csv export; expected [][]string or []map[string]string")
+		return errors.New("unsupported type for .csv export; expected [][]string, []map[string]string or []map[string]any")
 	}
 
 	return nil
 }
+
+// appendAsCSV appends one record, a struct or map, as a csv row. The header
+// is written with the first row and fixes the columns. For structs it holds
+// every json field of the type, also the omitempty ones the first record
+// leaves out; fields of maps that are not part of it are dropped.
+func appendAsCSV(data any, path string) error {
+	keys, values, err := csvFields(data)
+	if err != nil {
+		return err
+	}
+
+	header, err := readCSVHeader(path)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if header == nil {
+		header = keys
+		if columns := structColumns(data); columns != nil {
+			header = columns
+		}
+		if err := writer.Write(header); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(header))
+	for i, h := range header {
+		row[i] = values[h]
+	}
+	if err := writer.Write(row); err != nil {
+		return err
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// readCSVHeader returns the first row of the csv file at path, nil when the
+// file does not exist yet or is empty.
+func readCSVHeader(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header, err := csv.NewReader(file).Read()
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	return header, err
+}
+
+// csvFields returns the json field names of data in field order and their
+// values as csv cells.
+func csvFields(data any) ([]string, map[string]string, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	if tok, err := decoder.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("unsupported type %T for .csv append; expected a record struct or map", data)
+	}
+
+	var keys []string
+	values := make(map[string]string)
+	for decoder.More() {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = csvValue(raw)
+	}
+	return keys, values, nil
+}
+
+// structColumns returns the json field names of the struct type of data,
+// nil when data is not a struct.
+func structColumns(data any) []string {
+	t := reflect.TypeOf(data)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	var columns []string
+	for _, field := range reflect.VisibleFields(t) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = field.Name
+		}
+		columns = append(columns, name)
+	}
+	return columns
+}
+
+func csvCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+func csvValue(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	if string(raw) == "null" {
+		return ""
+	}
+	return string(raw)
+}
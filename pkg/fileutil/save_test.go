@@ -0,0 +1,44 @@
+package fileutil
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppendCSVKeepsOmittedColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stream.csv")
+	// the first record has none of the enrichment fields
+	require.NoError(t, SaveData(model.Record{IP: "1.1.1.1", DomainID: "localhost", RecordType: "A", Timestamp: 1}, path, "append"))
+	require.NoError(t, SaveData(model.Record{
+		IP: "1.1.1.1", DomainID: "www.example.com", RecordType: "A", Timestamp: 2,
+		ASN: 13335, ASNName: "CLOUDFLARENET", Country: "US", City: "San Francisco",
+		RegistrableDomain: "example.com", PublicSuffix: "com", Subdomain: "www",
+	}, path, "append"))
+
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"ip", "domain_id", "record_type", "timestamp", "asn", "asn_name", "country", "city", "registrable_domain", "public_suffix", "subdomain"}, rows[0])
+	assert.Equal(t, []string{"1.1.1.1", "localhost", "A", "1", "", "", "", "", "", "", ""}, rows[1])
+	assert.Equal(t, []string{"1.1.1.1", "www.example.com", "A", "2", "13335", "CLOUDFLARENET", "US", "San Francisco", "example.com", "com", "www"}, rows[2])
+}
+
+func TestAppendCSVMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rows.csv")
+	require.NoError(t, SaveData(map[string]any{"b": 1, "a": "x"}, path, "append"))
+	require.NoError(t, SaveData(map[string]any{"a": "y", "c": true}, path, "append"))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\nx,1\ny,\n", string(b))
+}
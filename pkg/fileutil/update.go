@@ -0,0 +1,93 @@
+package fileutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockTimeout bounds the wait for another process updating the same file
+	lockTimeout = 10 * time.Second
+	// staleLock is the age after which a lock is taken to be left behind by
+	// a process that crashed while holding it
+	staleLock = time.Minute
+)
+
+// UpdateJSON reads the json file at path into v, leaving v as it is when
+// the file does not exist, lets update change it and writes it back. A lock
+// file next to path serializes the updates of concurrent processes, and the
+// file is replaced by renaming a unique temp file over it, so readers never
+// see a partial write.
+func UpdateJSON(path string, v any, update func() error) error {
+	if err := EnsureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("invalid json file %s: %w", path, err)
+		}
+	}
+	if err := update(); err != nil {
+		return err
+	}
+
+	if data, err = json.MarshalIndent(v, "", "  "); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// lock creates the lock file at path, waiting up to lockTimeout while
+// another process holds it, and returns the func releasing it.
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another process, remove it if none is running", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,65 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateJSONConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "counts.json")
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var counts map[string]int
+			assert.NoError(t, UpdateJSON(path, &counts, func() error {
+				if counts == nil {
+					counts = map[string]int{}
+				}
+				counts["runs"]++
+				return nil
+			}))
+		}()
+	}
+	wg.Wait()
+
+	var counts map[string]int
+	require.NoError(t, UpdateJSON(path, &counts, func() error { return nil }))
+	assert.Equal(t, 20, counts["runs"], "no update is lost")
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "the lock and temp files are removed")
+}
+
+func TestUpdateJSONStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	require.NoError(t, os.WriteFile(path+".lock", nil, 0644))
+	old := time.Now().Add(-2 * staleLock)
+	require.NoError(t, os.Chtimes(path+".lock", old, old))
+
+	var counts map[string]int
+	assert.NoError(t, UpdateJSON(path, &counts, func() error {
+		counts = map[string]int{"runs": 1}
+		return nil
+	}))
+}
+
+func TestUpdateJSONInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counts.json")
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0644))
+
+	var counts map[string]int
+	err := UpdateJSON(path, &counts, func() error { return nil })
+	assert.ErrorContains(t, err, "invalid json file")
+	_, err = os.Stat(path + ".lock")
+	assert.ErrorIs(t, err, os.ErrNotExist, "the lock is released on error")
+}
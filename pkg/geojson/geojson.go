@@ -0,0 +1,118 @@
+package geojson
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Point is a record located at Lat, Long.
+type Point struct {
+	Lat     float64
+	Long    float64
+	Domain  string
+	City    string
+	Country string
+	ASN     int
+	ASNName string
+}
+
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+type Feature struct {
+	Type       string     `json:"type"`
+	Geometry   Geometry   `json:"geometry"`
+	Properties Properties `json:"properties"`
+
+	domains map[string]struct{}
+}
+
+// Geometry is a GeoJSON point, coordinates are [long, lat].
+type Geometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// Properties describe the records of a location. City, country and ASN
+// are taken from the first record seen there.
+type Properties struct {
+	City    string   `json:"city,omitempty"`
+	Country string   `json:"country,omitempty"`
+	ASN     int      `json:"asn,omitempty"`
+	ASNName string   `json:"asn_name,omitempty"`
+	Domains []string `json:"domains"`
+	Count   int      `json:"count"`
+}
+
+// Collection accumulates points into one feature per unique location.
+// It is safe for concurrent use.
+type Collection struct {
+	mu       sync.Mutex
+	features map[string]*Feature
+	order    []string
+}
+
+func New() *Collection {
+	return &Collection{features: map[string]*Feature{}}
+}
+
+// Add counts p at its location.
+func (c *Collection) Add(p Point) {
+	key := strconv.FormatFloat(p.Lat, 'f', -1, 64) + "," + strconv.FormatFloat(p.Long, 'f', -1, 64)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f, ok := c.features[key]
+	if !ok {
+		f = &Feature{
+			Type:     "Feature",
+			Geometry: Geometry{Type: "Point", Coordinates: [2]float64{p.Long, p.Lat}},
+			Properties: Properties{
+				City:    p.City,
+				Country: p.Country,
+				ASN:     p.ASN,
+				ASNName: p.ASNName,
+			},
+			domains: map[string]struct{}{},
+		}
+		c.features[key] = f
+		c.order = append(c.order, key)
+	}
+	f.Properties.Count++
+	if p.Domain != "" {
+		f.domains[p.Domain] = struct{}{}
+	}
+}
+
+// Len returns the number of unique locations.
+func (c *Collection) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.order)
+}
+
+// Write writes the locations as a FeatureCollection, in the order they
+// were first seen. Domains are sorted.
+func (c *Collection) Write(w io.Writer) error {
+	c.mu.Lock()
+	fc := FeatureCollection{Type: "FeatureCollection", Features: []*Feature{}}
+	for _, key := range c.order {
+		f := c.features[key]
+		f.Properties.Domains = make([]string, 0, len(f.domains))
+		for domain := range f.domains {
+			f.Properties.Domains = append(f.Properties.Domains, domain)
+		}
+		sort.Strings(f.Properties.Domains)
+		fc.Features = append(fc.Features, f)
+	}
+	c.mu.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(fc)
+}
@@ -0,0 +1,39 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionGroupsByLocation(t *testing.T) {
+	c := New()
+	c.Add(Point{Lat: 37.77, Long: -122.41, Domain: "b.example.com", City: "SF", Country: "US", ASN: 13335})
+	c.Add(Point{Lat: 37.77, Long: -122.41, Domain: "a.example.com"})
+	c.Add(Point{Lat: 37.77, Long: -122.41, Domain: "a.example.com"})
+	c.Add(Point{Lat: 52.52, Long: 13.40, Domain: "example.de", Country: "DE"})
+	assert.Equal(t, 2, c.Len())
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.Write(&buf))
+
+	var fc FeatureCollection
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &fc))
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	assert.Len(t, fc.Features, 2)
+
+	sf := fc.Features[0]
+	assert.Equal(t, [2]float64{-122.41, 37.77}, sf.Geometry.Coordinates)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, sf.Properties.Domains)
+	assert.Equal(t, 3, sf.Properties.Count)
+	assert.Equal(t, "SF", sf.Properties.City)
+	assert.Equal(t, 13335, sf.Properties.ASN)
+}
+
+func TestEmptyCollection(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, New().Write(&buf))
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[]}`, buf.String())
+}
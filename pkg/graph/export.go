@@ -0,0 +1,291 @@
+package graph
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats are the supported export formats.
+var Formats = []string{"json", "dot", "graphml", "gexf"}
+
+// FormatFromPath returns the export format matching the extension of path,
+// or "" if the extension is not a supported format.
+func FormatFromPath(path string) string {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	for _, format := range Formats {
+		if ext == format {
+			return format
+		}
+	}
+	return ""
+}
+
+// Write exports the graph in format, one of Formats.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "json":
+		return g.WriteJSON(w)
+	case "dot":
+		return g.WriteDOT(w)
+	case "graphml":
+		return g.WriteGraphML(w)
+	case "gexf":
+		return g.WriteGEXF(w)
+	default:
+		return fmt.Errorf("unsupported graph format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteDOT writes the graph as a Graphviz digraph.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph repclient {\n")
+	for _, node := range g.Nodes() {
+		fmt.Fprintf(&b, "  %s [label=%s, kind=%s, shape=%s", dotQuote(node.ID), dotQuote(node.Label), dotQuote(string(node.Kind)), dotShapes[node.Kind])
+		for _, key := range sortedKeys(node.Attrs) {
+			fmt.Fprintf(&b, ", %s=%s", dotQuote(key), dotQuote(node.Attrs[key]))
+		}
+		b.WriteString("];\n")
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s, timestamp=%d, count=%d];\n",
+			dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Type), edge.Timestamp, edge.Count)
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var dotShapes = map[Kind]string{
+	KindIP:      "box",
+	KindDomain:  "ellipse",
+	KindASN:     "hexagon",
+	KindCountry: "diamond",
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+type graphML struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteGraphML writes the graph as GraphML. Node attributes become
+// string keys prefixed with "attr_".
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	nodes, edges := g.Nodes(), g.Edges()
+	attrKeys := nodeAttrKeys(nodes)
+
+	doc := graphML{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "label", For: "node", Name: "label", Type: "string"},
+			{ID: "kind", For: "node", Name: "kind", Type: "string"},
+		},
+		Graph: graphMLGraph{ID: "repclient", EdgeDefault: "directed"},
+	}
+	for _, key := range attrKeys {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: "attr_" + key, For: "node", Name: key, Type: "string"})
+	}
+	doc.Keys = append(doc.Keys,
+		graphMLKey{ID: "type", For: "edge", Name: "type", Type: "string"},
+		graphMLKey{ID: "timestamp", For: "edge", Name: "timestamp", Type: "long"},
+		graphMLKey{ID: "count", For: "edge", Name: "count", Type: "int"},
+	)
+
+	for _, node := range nodes {
+		n := graphMLNode{ID: node.ID, Data: []graphMLData{
+			{Key: "label", Value: node.Label},
+			{Key: "kind", Value: string(node.Kind)},
+		}}
+		for _, key := range sortedKeys(node.Attrs) {
+			n.Data = append(n.Data, graphMLData{Key: "attr_" + key, Value: node.Attrs[key]})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+	for i, edge := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: edge.From,
+			Target: edge.To,
+			Data: []graphMLData{
+				{Key: "type", Value: edge.Type},
+				{Key: "timestamp", Value: strconv.FormatInt(edge.Timestamp, 10)},
+				{Key: "count", Value: strconv.Itoa(edge.Count)},
+			},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type gexf struct {
+	XMLName xml.Name  `xml:"gexf"`
+	XMLNS   string    `xml:"xmlns,attr"`
+	Version string    `xml:"version,attr"`
+	Graph   gexfGraph `xml:"graph"`
+}
+
+type gexfGraph struct {
+	DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+	Mode            string           `xml:"mode,attr"`
+	Attributes      []gexfAttributes `xml:"attributes"`
+	Nodes           []gexfNode       `xml:"nodes>node"`
+	Edges           []gexfEdge       `xml:"edges>edge"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfNode struct {
+	ID     string         `xml:"id,attr"`
+	Label  string         `xml:"label,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string         `xml:"id,attr"`
+	Source string         `xml:"source,attr"`
+	Target string         `xml:"target,attr"`
+	Label  string         `xml:"label,attr"`
+	Weight int            `xml:"weight,attr"`
+	Values []gexfAttValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// WriteGEXF writes the graph as GEXF 1.3, the native format of Gephi.
+// Edges are weighted by how often they were seen.
+func (g *Graph) WriteGEXF(w io.Writer) error {
+	nodes, edges := g.Nodes(), g.Edges()
+
+	nodeAttrs := gexfAttributes{Class: "node", Attributes: []gexfAttribute{{ID: "kind", Title: "kind", Type: "string"}}}
+	for _, key := range nodeAttrKeys(nodes) {
+		nodeAttrs.Attributes = append(nodeAttrs.Attributes, gexfAttribute{ID: "attr_" + key, Title: key, Type: "string"})
+	}
+	edgeAttrs := gexfAttributes{Class: "edge", Attributes: []gexfAttribute{
+		{ID: "type", Title: "type", Type: "string"},
+		{ID: "timestamp", Title: "timestamp", Type: "long"},
+	}}
+
+	doc := gexf{
+		XMLNS:   "http://gexf.net/1.3",
+		Version: "1.3",
+		Graph: gexfGraph{
+			DefaultEdgeType: "directed",
+			Mode:            "static",
+			Attributes:      []gexfAttributes{nodeAttrs, edgeAttrs},
+		},
+	}
+	for _, node := range nodes {
+		n := gexfNode{ID: node.ID, Label: node.Label, Values: []gexfAttValue{{For: "kind", Value: string(node.Kind)}}}
+		for _, key := range sortedKeys(node.Attrs) {
+			n.Values = append(n.Values, gexfAttValue{For: "attr_" + key, Value: node.Attrs[key]})
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, n)
+	}
+	for i, edge := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     "e" + strconv.Itoa(i),
+			Source: edge.From,
+			Target: edge.To,
+			Label:  edge.Type,
+			Weight: edge.Count,
+			Values: []gexfAttValue{
+				{For: "type", Value: edge.Type},
+				{For: "timestamp", Value: strconv.FormatInt(edge.Timestamp, 10)},
+			},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// nodeAttrKeys returns the sorted union of the attribute keys of nodes.
+func nodeAttrKeys(nodes []Node) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, node := range nodes {
+		for key := range node.Attrs {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,146 @@
+package graph
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+type Kind string
+
+const (
+	KindIP      Kind = "ip"
+	KindDomain  Kind = "domain"
+	KindASN     Kind = "asn"
+	KindCountry Kind = "country"
+)
+
+type Node struct {
+	ID    string            `json:"id"`
+	Kind  Kind              `json:"kind"`
+	Label string            `json:"label"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Edge links two nodes by ID. Edges are unique per From, To and Type,
+// Count is how often the relation was seen and Timestamp the latest time.
+type Edge struct {
+	From      string `json:"from"`
+	To        string `json:"to"`
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp,omitempty"`
+	Count     int    `json:"count"`
+}
+
+type edgeKey struct {
+	from, to, typ string
+}
+
+// Graph is a relationship graph safe for concurrent use. Nodes and edges
+// keep the order they were added in, so exports are stable.
+type Graph struct {
+	mu        sync.Mutex
+	nodes     map[string]*Node
+	nodeOrder []string
+	edges     map[edgeKey]*Edge
+	edgeOrder []edgeKey
+}
+
+func New() *Graph {
+	return &Graph{
+		nodes: map[string]*Node{},
+		edges: map[edgeKey]*Edge{},
+	}
+}
+
+// NodeID returns the ID of the node of kind with label.
+func NodeID(kind Kind, label string) string {
+	return string(kind) + ":" + label
+}
+
+// AddNode adds the node of kind with label if it does not exist yet and
+// returns its ID and whether it was added.
+func (g *Graph) AddNode(kind Kind, label string) (string, bool) {
+	id := NodeID(kind, label)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.nodes[id]; ok {
+		return id, false
+	}
+	g.nodes[id] = &Node{ID: id, Kind: kind, Label: label}
+	g.nodeOrder = append(g.nodeOrder, id)
+	return id, true
+}
+
+// SetAttr sets an attribute of an existing node.
+func (g *Graph) SetAttr(id, key, value string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	node, ok := g.nodes[id]
+	if !ok {
+		return
+	}
+	if node.Attrs == nil {
+		node.Attrs = map[string]string{}
+	}
+	node.Attrs[key] = value
+}
+
+// AddEdge adds an edge of typ between two nodes or updates the existing one.
+func (g *Graph) AddEdge(from, to, typ string, timestamp int64) {
+	key := edgeKey{from, to, typ}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &Edge{From: from, To: to, Type: typ}
+		g.edges[key] = edge
+		g.edgeOrder = append(g.edgeOrder, key)
+	}
+	edge.Count++
+	if timestamp > edge.Timestamp {
+		edge.Timestamp = timestamp
+	}
+}
+
+// Nodes returns a copy of every node.
+func (g *Graph) Nodes() []Node {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	nodes := make([]Node, 0, len(g.nodeOrder))
+	for _, id := range g.nodeOrder {
+		node := *g.nodes[id]
+		if node.Attrs != nil {
+			attrs := make(map[string]string, len(node.Attrs))
+			for k, v := range node.Attrs {
+				attrs[k] = v
+			}
+			node.Attrs = attrs
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// Edges returns a copy of every edge.
+func (g *Graph) Edges() []Edge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	edges := make([]Edge, 0, len(g.edgeOrder))
+	for _, key := range g.edgeOrder {
+		edges = append(edges, *g.edges[key])
+	}
+	return edges
+}
+
+// WriteJSON writes the graph as {"nodes": [...], "edges": [...]}.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}{g.Nodes(), g.Edges()})
+}
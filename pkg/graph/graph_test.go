@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGraphDedupesNodesAndEdges(t *testing.T) {
+	g := New()
+
+	id, added := g.AddNode(KindDomain, "example.com")
+	assert.True(t, added)
+	assert.Equal(t, "domain:example.com", id)
+	_, added = g.AddNode(KindDomain, "example.com")
+	assert.False(t, added)
+
+	ip, _ := g.AddNode(KindIP, "1.1.1.1")
+	g.AddEdge(id, ip, "A", 20)
+	g.AddEdge(id, ip, "A", 10)
+	g.AddEdge(id, ip, "AAAA", 5)
+
+	assert.Len(t, g.Nodes(), 2)
+	edges := g.Edges()
+	assert.Len(t, edges, 2)
+	assert.Equal(t, 2, edges[0].Count)
+	assert.Equal(t, int64(20), edges[0].Timestamp)
+}
+
+func TestGraphWriteJSON(t *testing.T) {
+	g := New()
+	id, _ := g.AddNode(KindIP, "1.1.1.1")
+	g.SetAttr(id, "depth", "0")
+
+	var buf bytes.Buffer
+	assert.NoError(t, g.WriteJSON(&buf))
+
+	var out struct {
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &out))
+	assert.Equal(t, "0", out.Nodes[0].Attrs["depth"])
+	assert.Empty(t, out.Edges)
+}
+
+func TestAddRecord(t *testing.T) {
+	g := New()
+	added := g.AddRecord(Record{DomainID: "example.com", IP: "1.1.1.1", RecordType: "A", ASN: 13335, ASNName: "CLOUDFLARENET", Country: "US"})
+	assert.Len(t, added, 4)
+	assert.Equal(t, KindASN, added[2].Kind)
+	assert.Equal(t, "AS13335", added[2].Label)
+
+	assert.Empty(t, g.AddRecord(Record{DomainID: "example.com", IP: "1.1.1.1", RecordType: "A"}))
+	assert.Nil(t, g.AddRecord(Record{DomainID: "example.com"}))
+	assert.Len(t, g.Edges(), 3)
+}
+
+func TestExportFormats(t *testing.T) {
+	g := New()
+	g.AddRecord(Record{DomainID: `a"b.com`, IP: "1.1.1.1", RecordType: "A", Timestamp: 7, Country: "US"})
+
+	var dot bytes.Buffer
+	assert.NoError(t, g.Write(&dot, "dot"))
+	assert.Contains(t, dot.String(), `"domain:a\"b.com" -> "ip:1.1.1.1" [label="A", timestamp=7, count=1];`)
+
+	for _, format := range []string{"graphml", "gexf"} {
+		var buf bytes.Buffer
+		assert.NoError(t, g.Write(&buf, format))
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), new(struct{})), format)
+		assert.Contains(t, buf.String(), "a&#34;b.com", format)
+	}
+
+	assert.Error(t, g.Write(&bytes.Buffer{}, "png"))
+	assert.Equal(t, "gexf", FormatFromPath("out/graph.GEXF"))
+	assert.Equal(t, "", FormatFromPath("graph.png"))
+}
@@ -0,0 +1,49 @@
+package graph
+
+import "strconv"
+
+// Record is the part of a DNS record that is turned into graph nodes.
+// Zero values are skipped, e.g. records without geo data only link a
+// domain to an IP.
+type Record struct {
+	DomainID   string
+	IP         string
+	RecordType string
+	Timestamp  int64
+	ASN        int
+	ASNName    string
+	Country    string
+}
+
+// AddRecord links the domain to the IP by record type and the IP to its
+// ASN and country. It returns the nodes that were not in the graph before.
+func (g *Graph) AddRecord(r Record) []Node {
+	var added []Node
+	add := func(kind Kind, label string) string {
+		id, ok := g.AddNode(kind, label)
+		if ok {
+			added = append(added, Node{ID: id, Kind: kind, Label: label})
+		}
+		return id
+	}
+
+	if r.DomainID == "" || r.IP == "" {
+		return nil
+	}
+	domainID := add(KindDomain, r.DomainID)
+	ipID := add(KindIP, r.IP)
+	g.AddEdge(domainID, ipID, r.RecordType, r.Timestamp)
+
+	if r.ASN != 0 {
+		asnID := add(KindASN, "AS"+strconv.Itoa(r.ASN))
+		if r.ASNName != "" {
+			g.SetAttr(asnID, "name", r.ASNName)
+		}
+		g.AddEdge(ipID, asnID, "ASN", r.Timestamp)
+	}
+	if r.Country != "" {
+		countryID := add(KindCountry, r.Country)
+		g.AddEdge(ipID, countryID, "COUNTRY", r.Timestamp)
+	}
+	return added
+}
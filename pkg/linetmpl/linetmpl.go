@@ -0,0 +1,116 @@
+// Package linetmpl renders records as lines with Go text/template.
+package linetmpl
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Presets are the built-in templates, selected by name. They only use the
+// fields shared by every record type.
+var Presets = map[string]string{
+	"domain":    "{{.DomainID}}",
+	"domain-ip": "{{.DomainID}},{{.IP}}",
+	"hosts":     "{{.IP}} {{.DomainID}}",
+	"csv":       "{{csv .DomainID}},{{csv .IP}},{{.Timestamp}}",
+	"tsv":       "{{.DomainID}}\t{{.IP}}\t{{rfc3339 .Timestamp}}",
+}
+
+// PresetNames returns the sorted preset names.
+func PresetNames() []string {
+	names := make([]string, 0, len(Presets))
+	for name := range Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Funcs are the helpers available to templates, timestamps are unix seconds.
+var Funcs = template.FuncMap{
+	"rfc3339": func(ts int64) string { return time.Unix(ts, 0).UTC().Format(time.RFC3339) },
+	"local":   func(ts int64) string { return time.Unix(ts, 0).Local().Format(time.RFC3339) },
+	"date":    func(ts int64) string { return time.Unix(ts, 0).UTC().Format(time.DateOnly) },
+	// formatTime takes the layout first so it can be piped: {{.Timestamp | formatTime "2006-01-02 15:04"}}
+	"formatTime": func(layout string, ts int64) string { return time.Unix(ts, 0).UTC().Format(layout) },
+	"join": func(sep string, values ...any) string {
+		parts := make([]string, len(values))
+		for i, v := range values {
+			parts[i] = fmt.Sprint(v)
+		}
+		return strings.Join(parts, sep)
+	},
+	"csv":   csvEscape,
+	"json":  jsonEscape,
+	"quote": func(v any) string { return strconv.Quote(fmt.Sprint(v)) },
+	"upper": func(v any) string { return strings.ToUpper(fmt.Sprint(v)) },
+	"lower": func(v any) string { return strings.ToLower(fmt.Sprint(v)) },
+	"default": func(def, v any) any {
+		if v == nil || fmt.Sprint(v) == "" || fmt.Sprint(v) == "0" {
+			return def
+		}
+		return v
+	},
+}
+
+// Template renders one line per record.
+type Template struct {
+	tmpl *template.Template
+}
+
+// Parse parses spec, which is a preset name, "@" followed by the path of a
+// template file, or an inline template.
+func Parse(spec string) (*Template, error) {
+	text := spec
+	switch {
+	case spec == "":
+		return nil, fmt.Errorf("empty template, use a preset (%s), @file or an inline template", strings.Join(PresetNames(), ", "))
+	case Presets[spec] != "":
+		text = Presets[spec]
+	case strings.HasPrefix(spec, "@"):
+		b, err := os.ReadFile(spec[1:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		text = strings.TrimRight(string(b), "\r\n")
+	}
+
+	tmpl, err := template.New("line").Funcs(Funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return &Template{tmpl: tmpl}, nil
+}
+
+// Execute renders record, the result never ends with a newline.
+func (t *Template) Execute(record any) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, record); err != nil {
+		return "", fmt.Errorf("template error: %w", err)
+	}
+	return strings.TrimRight(buf.String(), "\r\n"), nil
+}
+
+// csvEscape quotes v as a single csv field when needed.
+func csvEscape(v any) (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write([]string{fmt.Sprint(v)}); err != nil {
+		return "", err
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n"), w.Error()
+}
+
+func jsonEscape(v any) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
@@ -0,0 +1,62 @@
+package linetmpl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Doom-z/RepClient/client/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var record = model.Record{IP: "1.2.3.4", DomainID: "a,b.example.com", RecordType: "A", Timestamp: 1700000000}
+
+func TestPresets(t *testing.T) {
+	for name, want := range map[string]string{
+		"domain":    "a,b.example.com",
+		"domain-ip": "a,b.example.com,1.2.3.4",
+		"hosts":     "1.2.3.4 a,b.example.com",
+		"csv":       `"a,b.example.com",1.2.3.4,1700000000`,
+		"tsv":       "a,b.example.com\t1.2.3.4\t2023-11-14T22:13:20Z",
+	} {
+		tmpl, err := Parse(name)
+		require.NoError(t, err, name)
+		line, err := tmpl.Execute(record)
+		require.NoError(t, err, name)
+		assert.Equal(t, want, line, name)
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	tmpl, err := Parse(`{{join "|" .IP (upper .RecordType) (date .Timestamp)}} {{.Timestamp | formatTime "15:04"}} {{json .DomainID}} {{default "-" .Country}}`)
+	require.NoError(t, err)
+	line, err := tmpl.Execute(record)
+	require.NoError(t, err)
+	assert.Equal(t, `1.2.3.4|A|2023-11-14 22:13 "a,b.example.com" -`, line)
+}
+
+func TestParseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "line.tmpl")
+	require.NoError(t, os.WriteFile(path, []byte("{{.IP}}\n"), 0644))
+
+	tmpl, err := Parse("@" + path)
+	require.NoError(t, err)
+	line, err := tmpl.Execute(record)
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.4", line)
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Parse("")
+	assert.Error(t, err)
+	_, err = Parse("{{.IP")
+	assert.Error(t, err)
+	_, err = Parse("@does-not-exist.tmpl")
+	assert.Error(t, err)
+
+	tmpl, err := Parse("{{.LatLong}}")
+	require.NoError(t, err)
+	_, err = tmpl.Execute(record)
+	assert.Error(t, err)
+}
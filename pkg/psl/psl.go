@@ -0,0 +1,114 @@
+// Package psl splits domains by the Public Suffix List. The list is
+// embedded, nothing is looked up at runtime. To update the snapshot run
+//
+//	go generate ./pkg/psl
+//
+// and commit the new public_suffix_list.dat.
+package psl
+
+//go:generate curl -fsSL -o public_suffix_list.dat https://publicsuffix.org/list/public_suffix_list.dat
+
+import (
+	"bufio"
+	_ "embed"
+	"strings"
+	"sync"
+)
+
+//go:embed public_suffix_list.dat
+var list string
+
+var (
+	once  sync.Once
+	rules map[string]ruleType
+)
+
+// ruleType is a set of flags, the same name can be listed as a normal
+// and as a wildcard rule.
+type ruleType uint8
+
+const (
+	ruleNormal ruleType = 1 << iota
+	ruleWildcard
+	ruleException
+)
+
+// Parts is a domain split at its public suffix, e.g. a.b.example.co.uk has
+// the subdomain a.b, the registrable domain example.co.uk and the public
+// suffix co.uk.
+type Parts struct {
+	Subdomain         string `json:"subdomain"`
+	RegistrableDomain string `json:"registrable_domain"`
+	PublicSuffix      string `json:"public_suffix"`
+}
+
+func load() {
+	rules = map[string]ruleType{}
+	scanner := bufio.NewScanner(strings.NewReader(list))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "//") {
+			continue
+		}
+		// rules end at the first whitespace
+		line = strings.Fields(line)[0]
+		switch {
+		case strings.HasPrefix(line, "!"):
+			rules[line[1:]] |= ruleException
+		case strings.HasPrefix(line, "*."):
+			rules[line[2:]] |= ruleWildcard
+		default:
+			rules[line] |= ruleNormal
+		}
+	}
+}
+
+// Split returns the parts of domain. The registrable domain is empty when
+// domain is a public suffix itself. Domains are lowercased and a trailing
+// dot is removed, unlisted TLDs are treated as public suffixes.
+func Split(domain string) Parts {
+	once.Do(load)
+
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return Parts{}
+	}
+	labels := strings.Split(domain, ".")
+
+	// index of the first label of the public suffix, the implicit "*" rule
+	// makes the last label a suffix
+	suffix := len(labels) - 1
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		if rules[candidate]&ruleException != 0 {
+			suffix = i + 1
+			break
+		}
+		if rules[candidate]&ruleNormal != 0 {
+			suffix = i
+			break
+		}
+		if i+1 < len(labels) && rules[strings.Join(labels[i+1:], ".")]&ruleWildcard != 0 {
+			suffix = i
+			break
+		}
+	}
+
+	parts := Parts{PublicSuffix: strings.Join(labels[suffix:], ".")}
+	if suffix > 0 {
+		parts.RegistrableDomain = strings.Join(labels[suffix-1:], ".")
+		parts.Subdomain = strings.Join(labels[:suffix-1], ".")
+	}
+	return parts
+}
+
+// RegistrableDomain returns the public suffix of domain plus one label
+// (eTLD+1), or "" when domain is a public suffix.
+func RegistrableDomain(domain string) string {
+	return Split(domain).RegistrableDomain
+}
+
+// PublicSuffix returns the public suffix (eTLD) of domain.
+func PublicSuffix(domain string) string {
+	return Split(domain).PublicSuffix
+}
@@ -0,0 +1,23 @@
+package psl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit(t *testing.T) {
+	tests := map[string]Parts{
+		"a.b.example.co.uk": {Subdomain: "a.b", RegistrableDomain: "example.co.uk", PublicSuffix: "co.uk"},
+		"Example.COM.":      {RegistrableDomain: "example.com", PublicSuffix: "com"},
+		"co.uk":             {PublicSuffix: "co.uk"},
+		"foo.bar.ck":        {RegistrableDomain: "foo.bar.ck", PublicSuffix: "bar.ck"},
+		"a.www.ck":          {Subdomain: "a", RegistrableDomain: "www.ck", PublicSuffix: "ck"},
+		"x.city.kobe.jp":    {Subdomain: "x", RegistrableDomain: "city.kobe.jp", PublicSuffix: "kobe.jp"},
+		"host.internal":     {RegistrableDomain: "host.internal", PublicSuffix: "internal"},
+		"":                  {},
+	}
+	for domain, want := range tests {
+		assert.Equal(t, want, Split(domain), domain)
+	}
+}
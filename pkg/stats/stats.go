@@ -0,0 +1,207 @@
+package stats
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/psl"
+	"github.com/Doom-z/RepClient/pkg/timefmt"
+)
+
+// Dimensions are the supported groupings, in report order:
+//   - asn, asn_name, country, city: the record fields of the same name
+//   - domain: the registrable domain (eTLD+1) of domain_id
+//   - public_suffix: the public suffix (eTLD) of domain_id
+//   - day: the UTC day of the record timestamp, i.e. when it was first seen
+var Dimensions = []string{"asn", "asn_name", "country", "city", "domain", "public_suffix", "day"}
+
+// Formats are the supported report formats.
+var Formats = []string{"table", "json", "csv"}
+
+type Entry struct {
+	Value   string  `json:"value"`
+	Count   int     `json:"count"`
+	Percent float64 `json:"percent"`
+}
+
+// Group holds the counts of one dimension. Percentages are relative to all
+// records, Missing counts the records without a value for the dimension and
+// Other the records of the values cut by the top limit.
+type Group struct {
+	Dimension string  `json:"dimension"`
+	Entries   []Entry `json:"entries"`
+	Other     int     `json:"other"`
+	Missing   int     `json:"missing"`
+}
+
+type Report struct {
+	Total  int     `json:"total"`
+	Groups []Group `json:"groups"`
+}
+
+// Aggregator counts records by dimension. It is not safe for concurrent use.
+type Aggregator struct {
+	dims   []string
+	total  int
+	counts map[string]map[string]int
+}
+
+// New creates an aggregator over dims, every dimension when empty.
+func New(dims []string) (*Aggregator, error) {
+	if len(dims) == 0 {
+		dims = Dimensions
+	}
+	a := &Aggregator{dims: dims, counts: map[string]map[string]int{}}
+	for _, dim := range dims {
+		if !slices.Contains(Dimensions, dim) {
+			return nil, fmt.Errorf("unknown dimension %q, expected one of %s", dim, strings.Join(Dimensions, ", "))
+		}
+		a.counts[dim] = map[string]int{}
+	}
+	return a, nil
+}
+
+// Add counts a record in the map form of fileutil.LoadRecords.
+func (a *Aggregator) Add(record map[string]any) {
+	a.total++
+	for _, dim := range a.dims {
+		if value := dimensionValue(record, dim); value != "" {
+			a.counts[dim][value]++
+		}
+	}
+}
+
+// Report returns the counts of every dimension, largest first. Only the
+// top entries are kept when top is greater than 0.
+func (a *Aggregator) Report(top int) Report {
+	report := Report{Total: a.total}
+	for _, dim := range a.dims {
+		group := Group{Dimension: dim, Missing: a.total}
+		for value, count := range a.counts[dim] {
+			group.Entries = append(group.Entries, Entry{Value: value, Count: count, Percent: percent(count, a.total)})
+			group.Missing -= count
+		}
+		sort.Slice(group.Entries, func(i, j int) bool {
+			ei, ej := group.Entries[i], group.Entries[j]
+			if ei.Count != ej.Count {
+				return ei.Count > ej.Count
+			}
+			return ei.Value < ej.Value
+		})
+		if top > 0 && len(group.Entries) > top {
+			for _, entry := range group.Entries[top:] {
+				group.Other += entry.Count
+			}
+			group.Entries = group.Entries[:top]
+		}
+		report.Groups = append(report.Groups, group)
+	}
+	return report
+}
+
+func dimensionValue(record map[string]any, dim string) string {
+	switch dim {
+	case "domain":
+		if value := stringify(record["registrable_domain"]); value != "" {
+			return value
+		}
+		return psl.RegistrableDomain(stringify(record["domain_id"]))
+	case "public_suffix":
+		if value := stringify(record["public_suffix"]); value != "" {
+			return value
+		}
+		return psl.PublicSuffix(stringify(record["domain_id"]))
+	case "day":
+		ts, ok := timefmt.Unix(record["timestamp"])
+		if !ok || ts <= 0 {
+			return ""
+		}
+		return time.Unix(ts, 0).UTC().Format(time.DateOnly)
+	case "asn":
+		if value := stringify(record["asn"]); value != "0" {
+			return value
+		}
+		return ""
+	default:
+		return stringify(record[dim])
+	}
+}
+
+// Write renders the report in format, one of Formats.
+func (r Report) Write(w io.Writer, format string) error {
+	switch format {
+	case "table":
+		return r.WriteTable(w)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case "csv":
+		return r.WriteCSV(w)
+	default:
+		return fmt.Errorf("unsupported stats format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteTable writes one aligned table per dimension.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Total records: %d\n", r.Total)
+	for _, group := range r.Groups {
+		fmt.Fprintf(tw, "\n%s\tCOUNT\tPERCENT\n", strings.ToUpper(group.Dimension))
+		for _, entry := range group.Entries {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f%%\n", entry.Value, entry.Count, entry.Percent)
+		}
+		if group.Other > 0 {
+			fmt.Fprintf(tw, "(other)\t%d\t%.2f%%\n", group.Other, percent(group.Other, r.Total))
+		}
+		if group.Missing > 0 {
+			fmt.Fprintf(tw, "(missing)\t%d\t%.2f%%\n", group.Missing, percent(group.Missing, r.Total))
+		}
+	}
+	return tw.Flush()
+}
+
+// WriteCSV writes one dimension,value,count,percent row per entry.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"dimension", "value", "count", "percent"})
+	row := func(dim, value string, count int) {
+		cw.Write([]string{dim, value, strconv.Itoa(count), strconv.FormatFloat(percent(count, r.Total), 'f', 2, 64)})
+	}
+	for _, group := range r.Groups {
+		for _, entry := range group.Entries {
+			row(group.Dimension, entry.Value, entry.Count)
+		}
+		if group.Other > 0 {
+			row(group.Dimension, "(other)", group.Other)
+		}
+		if group.Missing > 0 {
+			row(group.Dimension, "(missing)", group.Missing)
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func percent(count, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+func stringify(v any) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,52 @@
+package stats
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregatorReport(t *testing.T) {
+	agg, err := New([]string{"asn", "domain", "day"})
+	assert.NoError(t, err)
+
+	agg.Add(map[string]any{"asn": json.Number("13335"), "domain_id": "a.example.com", "timestamp": json.Number("1700000000")})
+	agg.Add(map[string]any{"asn": json.Number("13335"), "domain_id": "b.example.com", "timestamp": json.Number("1700086400")})
+	agg.Add(map[string]any{"asn": json.Number("15169"), "domain_id": "example.org"})
+	agg.Add(map[string]any{"domain_id": "c.example.org"})
+
+	report := agg.Report(1)
+	assert.Equal(t, 4, report.Total)
+
+	asn := report.Groups[0]
+	assert.Equal(t, []Entry{{Value: "13335", Count: 2, Percent: 50}}, asn.Entries)
+	assert.Equal(t, 1, asn.Other)
+	assert.Equal(t, 1, asn.Missing)
+
+	domain := report.Groups[1]
+	assert.Equal(t, "example.com", domain.Entries[0].Value)
+	assert.Equal(t, 2, domain.Other)
+
+	day := report.Groups[2]
+	assert.Equal(t, "2023-11-14", day.Entries[0].Value)
+	assert.Equal(t, 2, day.Missing)
+}
+
+func TestUnknownDimension(t *testing.T) {
+	_, err := New([]string{"rir"})
+	assert.Error(t, err)
+}
+
+func TestReportWriteCSV(t *testing.T) {
+	agg, _ := New([]string{"country"})
+	agg.Add(map[string]any{"country": "US"})
+	agg.Add(map[string]any{"country": "DE"})
+	agg.Add(map[string]any{"country": "US"})
+
+	var buf bytes.Buffer
+	assert.NoError(t, agg.Report(0).Write(&buf, "csv"))
+	assert.Equal(t, "dimension,value,count,percent\ncountry,US,2,66.67\ncountry,DE,1,33.33\n", buf.String())
+	assert.Error(t, agg.Report(0).Write(&buf, "xml"))
+}
@@ -0,0 +1,109 @@
+// Package timefmt renders the unix timestamps of records.
+package timefmt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Mode string
+
+const (
+	// Epoch keeps the raw unix seconds.
+	Epoch Mode = "epoch"
+	// RFC3339 replaces the timestamp with an RFC 3339 time in UTC.
+	RFC3339 Mode = "rfc3339"
+	// Local replaces the timestamp with an RFC 3339 time in the local time zone.
+	Local Mode = "local"
+	// Both keeps the raw unix seconds and adds an RFC 3339 UTC "time" field.
+	Both Mode = "both"
+)
+
+var Modes = []Mode{Epoch, RFC3339, Local, Both}
+
+// Field is the record field holding the timestamp, TimeField the field
+// added by Both.
+const (
+	Field     = "timestamp"
+	TimeField = "time"
+)
+
+// ParseMode parses s, an empty s is Epoch.
+func ParseMode(s string) (Mode, error) {
+	if s == "" {
+		return Epoch, nil
+	}
+	for _, m := range Modes {
+		if Mode(s) == m {
+			return m, nil
+		}
+	}
+	modes := make([]string, len(Modes))
+	for i, m := range Modes {
+		modes[i] = string(m)
+	}
+	return "", fmt.Errorf("invalid timestamp format %q, expected one of %s", s, strings.Join(modes, ", "))
+}
+
+// Fields returns the fields representing ts in mode.
+func (m Mode) Fields(ts int64) map[string]any {
+	t := time.Unix(ts, 0)
+	switch m {
+	case RFC3339:
+		return map[string]any{Field: t.UTC().Format(time.RFC3339)}
+	case Local:
+		return map[string]any{Field: t.Local().Format(time.RFC3339)}
+	case Both:
+		return map[string]any{Field: ts, TimeField: t.UTC().Format(time.RFC3339)}
+	default:
+		return map[string]any{Field: ts}
+	}
+}
+
+// Apply rewrites the timestamp field of record, a record without one is
+// left as is. Epoch turns rendered timestamps back into unix seconds.
+func (m Mode) Apply(record map[string]any) {
+	ts, ok := Unix(record[Field])
+	if !ok {
+		return
+	}
+	for k, v := range m.Fields(ts) {
+		record[k] = v
+	}
+}
+
+// Unix converts a timestamp field value back to unix seconds. It accepts
+// the numbers of decoded records and the strings written by RFC3339 and Local.
+func Unix(v any) (int64, bool) {
+	switch ts := v.(type) {
+	case int64:
+		return ts, true
+	case int:
+		return int64(ts), true
+	case float64:
+		return int64(ts), true
+	case json.Number:
+		n, err := ts.Int64()
+		return n, err == nil
+	case string:
+		if n, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			return n, true
+		}
+		if t, err := time.Parse(time.RFC3339, ts); err == nil {
+			return t.Unix(), true
+		}
+	}
+	return 0, false
+}
+
+// Format renders ts as a single string, for log messages.
+func (m Mode) Format(ts int64) string {
+	fields := m.Fields(ts)
+	if m == Both {
+		return fmt.Sprintf("%d (%s)", ts, fields[TimeField])
+	}
+	return fmt.Sprint(fields[Field])
+}
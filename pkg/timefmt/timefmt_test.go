@@ -0,0 +1,60 @@
+package timefmt
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	record := func() map[string]any {
+		return map[string]any{"ip": "1.1.1.1", "timestamp": json.Number("1700000000")}
+	}
+
+	r := record()
+	Epoch.Apply(r)
+	assert.Equal(t, int64(1700000000), r["timestamp"])
+
+	r = map[string]any{"timestamp": "2023-11-14T22:13:20Z"}
+	Epoch.Apply(r)
+	assert.Equal(t, int64(1700000000), r["timestamp"])
+
+	r = record()
+	RFC3339.Apply(r)
+	assert.Equal(t, "2023-11-14T22:13:20Z", r["timestamp"])
+
+	r = record()
+	Both.Apply(r)
+	assert.Equal(t, int64(1700000000), r["timestamp"])
+	assert.Equal(t, "2023-11-14T22:13:20Z", r["time"])
+
+	r = record()
+	Local.Apply(r)
+	ts, ok := Unix(r["timestamp"])
+	assert.True(t, ok)
+	assert.Equal(t, int64(1700000000), ts)
+
+	r = map[string]any{"domain_id": "example.com"}
+	RFC3339.Apply(r)
+	assert.NotContains(t, r, "timestamp")
+}
+
+func TestParseMode(t *testing.T) {
+	m, err := ParseMode("")
+	assert.NoError(t, err)
+	assert.Equal(t, Epoch, m)
+
+	m, err = ParseMode("both")
+	assert.NoError(t, err)
+	assert.Equal(t, Both, m)
+
+	_, err = ParseMode("iso")
+	assert.Error(t, err)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "1700000000", Epoch.Format(1700000000))
+	assert.Equal(t, "2023-11-14T22:13:20Z", RFC3339.Format(1700000000))
+	assert.Equal(t, "1700000000 (2023-11-14T22:13:20Z)", Both.Format(1700000000))
+}
@@ -0,0 +1,406 @@
+// Package usage counts the API requests, pages and records of runs per
+// endpoint and api key, keeps them in a local ledger and enforces daily
+// request limits.
+package usage
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+	"github.com/Doom-z/RepClient/pkg/utils"
+)
+
+// TrialRecords is the result cap of the trial API, counted per UTC day.
+const TrialRecords = 1000
+
+// trialWarnRatio is the share of TrialRecords warned about ahead of the cap.
+const trialWarnRatio = 0.9
+
+// ErrLimitReached is returned for requests over the daily request limit.
+var ErrLimitReached = errors.New("daily request limit reached")
+
+var (
+	// Periods are the supported report periods.
+	Periods = []string{"day", "month"}
+	// Groups are the supported report groupings next to the period.
+	Groups = []string{"endpoint", "key"}
+	// Formats are the supported report formats.
+	Formats = []string{"table", "json", "csv"}
+)
+
+type Counts struct {
+	Requests int `json:"requests"`
+	Pages    int `json:"pages"`
+	Records  int `json:"records"`
+	Errors   int `json:"errors"`
+}
+
+func (c *Counts) add(o Counts) {
+	c.Requests += o.Requests
+	c.Pages += o.Pages
+	c.Records += o.Records
+	c.Errors += o.Errors
+}
+
+// Entry counts the calls of one endpoint with one api key on one UTC day.
+type Entry struct {
+	Day      string `json:"day"`
+	Endpoint string `json:"endpoint"`
+	// Key is the redacted api key, shown in reports
+	Key string `json:"key"`
+	// KeyID is a hash of the api key telling apart keys that redact the
+	// same, empty in ledgers written before it was added
+	KeyID string `json:"key_id,omitempty"`
+	Counts
+}
+
+type entryKey struct {
+	day, endpoint, key, keyID string
+}
+
+// keyID hashes an api key the way the key pool does, the entries never hold
+// the key itself.
+func keyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Limits of a Meter, 0 disables a limit.
+type Limits struct {
+	// MaxRequestsPerDay refuses requests once reached
+	MaxRequestsPerDay int
+	// SoftRequestsPerDay logs a warning once reached
+	SoftRequestsPerDay int
+	// TrialRecords warns ahead of and at the result cap of the trial API
+	TrialRecords int
+}
+
+// Meter counts the requests of a run and checks them against the limits,
+// starting from the totals of the ledger. It is safe for concurrent use.
+type Meter struct {
+	mu      sync.Mutex
+	limits  Limits
+	day     string
+	today   Counts
+	ledger  []Entry
+	run     map[entryKey]*Counts
+	unsaved map[entryKey]*Counts
+	warned  map[string]bool
+}
+
+// NewMeter creates a meter with limits, ledger are the entries of previous
+// runs counting towards the daily limits.
+func NewMeter(limits Limits, ledger []Entry) *Meter {
+	m := &Meter{
+		limits:  limits,
+		ledger:  ledger,
+		run:     map[entryKey]*Counts{},
+		unsaved: map[entryKey]*Counts{},
+		warned:  map[string]bool{},
+	}
+	m.rollDay(time.Now())
+	return m
+}
+
+// Request counts a request to endpoint with apiKey, it returns an error
+// wrapping ErrLimitReached instead when the daily limit is used up.
+func (m *Meter) Request(endpoint, apiKey string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rollDay(time.Now())
+
+	if limit := m.limits.MaxRequestsPerDay; limit > 0 && m.today.Requests >= limit {
+		return fmt.Errorf("%w: %d of %d requests used today", ErrLimitReached, m.today.Requests, limit)
+	}
+	m.count(endpoint, apiKey, Counts{Requests: 1})
+	if soft := m.limits.SoftRequestsPerDay; soft > 0 && m.today.Requests >= soft {
+		m.warnOnce("soft", "soft request limit reached: %d of %d requests used today", m.today.Requests, soft)
+	}
+	return nil
+}
+
+// Page counts a page of records returned by endpoint.
+func (m *Meter) Page(endpoint, apiKey string, records int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count(endpoint, apiKey, Counts{Pages: 1, Records: records})
+
+	if limit := m.limits.TrialRecords; limit > 0 {
+		switch {
+		case m.today.Records >= limit:
+			m.warnOnce("trial", "trial result cap reached: %d of %d records today, further results may be cut", m.today.Records, limit)
+		case float64(m.today.Records) >= trialWarnRatio*float64(limit):
+			m.warnOnce("trial-near", "close to the trial result cap: %d of %d records today", m.today.Records, limit)
+		}
+	}
+}
+
+// Failed counts a failed request to endpoint.
+func (m *Meter) Failed(endpoint, apiKey string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.count(endpoint, apiKey, Counts{Errors: 1})
+}
+
+func (m *Meter) count(endpoint, apiKey string, c Counts) {
+	k := entryKey{day: m.day, endpoint: endpoint, key: utils.RedactSecret(apiKey), keyID: keyID(apiKey)}
+	for _, counts := range []map[entryKey]*Counts{m.run, m.unsaved} {
+		if counts[k] == nil {
+			counts[k] = &Counts{}
+		}
+		counts[k].add(c)
+	}
+	m.today.add(c)
+}
+
+func (m *Meter) warnOnce(id, format string, a ...any) {
+	if m.warned[id] {
+		return
+	}
+	m.warned[id] = true
+	logger.Warnf(format, a...)
+}
+
+// rollDay restarts the daily totals when the UTC day changed.
+func (m *Meter) rollDay(now time.Time) {
+	day := now.UTC().Format(time.DateOnly)
+	if day == m.day {
+		return
+	}
+	m.day = day
+	m.today = Counts{}
+	m.warned = map[string]bool{}
+	for _, e := range m.ledger {
+		if e.Day == day {
+			m.today.add(e.Counts)
+		}
+	}
+	for k, c := range m.run {
+		if k.day == day {
+			m.today.add(*c)
+		}
+	}
+}
+
+// Today returns the totals of the current UTC day, including the ledger.
+func (m *Meter) Today() Counts {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.today
+}
+
+// Entries returns the counts of the run.
+func (m *Meter) Entries() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return entries(m.run)
+}
+
+// Flush returns the counts since the last flush, to be added to the ledger.
+func (m *Meter) Flush() []Entry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	flushed := entries(m.unsaved)
+	m.unsaved = map[entryKey]*Counts{}
+	return flushed
+}
+
+func entries(counts map[entryKey]*Counts) []Entry {
+	out := make([]Entry, 0, len(counts))
+	for k, c := range counts {
+		out = append(out, Entry{Day: k.day, Endpoint: k.endpoint, Key: k.key, KeyID: k.keyID, Counts: *c})
+	}
+	sortEntries(out)
+	return out
+}
+
+func sortEntries(entries []Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Day != b.Day {
+			return a.Day < b.Day
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.KeyID < b.KeyID
+	})
+}
+
+// Ledger is a json file with the counts of every day, endpoint and key.
+type Ledger struct {
+	Path string
+}
+
+type ledgerFile struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Load returns the entries of the ledger, none if the file does not exist.
+func (l Ledger) Load() ([]Entry, error) {
+	data, err := os.ReadFile(l.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file ledgerFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid usage ledger %s: %w", l.Path, err)
+	}
+	return file.Entries, nil
+}
+
+// Add merges added into the ledger. The file is read again under a lock
+// first, so concurrent runs sharing it do not lose each other's counts.
+func (l Ledger) Add(added []Entry) error {
+	if len(added) == 0 {
+		return nil
+	}
+	var file ledgerFile
+	return fileutil.UpdateJSON(l.Path, &file, func() error {
+		merged := map[entryKey]*Counts{}
+		for _, e := range append(file.Entries, added...) {
+			k := entryKey{day: e.Day, endpoint: e.Endpoint, key: e.Key, keyID: e.KeyID}
+			if merged[k] == nil {
+				merged[k] = &Counts{}
+			}
+			merged[k].add(e.Counts)
+		}
+		file.Entries = entries(merged)
+		return nil
+	})
+}
+
+// Total are the counts of one period, and group when grouped.
+type Total struct {
+	Period string `json:"period"`
+	Group  string `json:"group,omitempty"`
+	Counts
+}
+
+// Summarize adds up entries per period, day or month, and per endpoint or
+// key when by is set. Keys are grouped by their id and shown redacted, with
+// the id when there is one. Only the last periods are kept when last is positive.
+func Summarize(entries []Entry, period, by string, last int) ([]Total, error) {
+	periodOf := func(e Entry) string { return e.Day }
+	switch period {
+	case "day":
+	case "month":
+		periodOf = func(e Entry) string { return e.Day[:min(len(e.Day), len("2006-01"))] }
+	default:
+		return nil, fmt.Errorf("unsupported period %q, expected one of %s", period, strings.Join(Periods, ", "))
+	}
+	groupOf := func(Entry) string { return "" }
+	switch by {
+	case "":
+	case "endpoint":
+		groupOf = func(e Entry) string { return e.Endpoint }
+	case "key":
+		groupOf = func(e Entry) string {
+			if e.KeyID == "" {
+				return e.Key
+			}
+			return e.Key + " " + e.KeyID
+		}
+	default:
+		return nil, fmt.Errorf("unsupported grouping %q, expected one of %s", by, strings.Join(Groups, ", "))
+	}
+
+	index := map[[2]string]*Total{}
+	periods := map[string]bool{}
+	for _, e := range entries {
+		k := [2]string{periodOf(e), groupOf(e)}
+		if index[k] == nil {
+			index[k] = &Total{Period: k[0], Group: k[1]}
+		}
+		index[k].add(e.Counts)
+		periods[k[0]] = true
+	}
+
+	keep := map[string]bool{}
+	sorted := make([]string, 0, len(periods))
+	for p := range periods {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+	if last > 0 && len(sorted) > last {
+		sorted = sorted[len(sorted)-last:]
+	}
+	for _, p := range sorted {
+		keep[p] = true
+	}
+
+	var totals []Total
+	for _, t := range index {
+		if keep[t.Period] {
+			totals = append(totals, *t)
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Period != totals[j].Period {
+			return totals[i].Period < totals[j].Period
+		}
+		return totals[i].Group < totals[j].Group
+	})
+	return totals, nil
+}
+
+// WriteTotals writes totals as an aligned table, json or csv. by is the
+// grouping the totals were summarized with.
+func WriteTotals(w io.Writer, totals []Total, by, format string) error {
+	header := []string{"period", "requests", "pages", "records", "errors"}
+	row := func(t Total) []string {
+		return []string{t.Period, strconv.Itoa(t.Requests), strconv.Itoa(t.Pages), strconv.Itoa(t.Records), strconv.Itoa(t.Errors)}
+	}
+	if by != "" {
+		header = append(header[:1], append([]string{by}, header[1:]...)...)
+		plain := row
+		row = func(t Total) []string {
+			r := plain(t)
+			return append(r[:1], append([]string{t.Group}, r[1:]...)...)
+		}
+	}
+
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		for _, t := range totals {
+			fmt.Fprintln(tw, strings.Join(row(t), "\t"))
+		}
+		return tw.Flush()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(totals)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(header)
+		for _, t := range totals {
+			cw.Write(row(t))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		return fmt.Errorf("unsupported usage format %q, expected one of %s", format, strings.Join(Formats, ", "))
+	}
+}
@@ -0,0 +1,101 @@
+package usage
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeterLimits(t *testing.T) {
+	today := time.Now().UTC().Format(time.DateOnly)
+	ledger := []Entry{
+		{Day: today, Endpoint: "/api/dns", Key: "****", Counts: Counts{Requests: 2}},
+		{Day: "2000-01-01", Endpoint: "/api/dns", Key: "****", Counts: Counts{Requests: 100}},
+	}
+	m := NewMeter(Limits{MaxRequestsPerDay: 3}, ledger)
+
+	require.NoError(t, m.Request("/api/dns/paging", "secret-api-key"))
+	m.Page("/api/dns/paging", "secret-api-key", 25)
+	assert.ErrorIs(t, m.Request("/api/dns/paging", "secret-api-key"), ErrLimitReached)
+
+	assert.Equal(t, Counts{Requests: 3, Pages: 1, Records: 25}, m.Today())
+	assert.Equal(t, []Entry{
+		{Day: today, Endpoint: "/api/dns/paging", Key: "****-key", KeyID: keyID("secret-api-key"), Counts: Counts{Requests: 1, Pages: 1, Records: 25}},
+	}, m.Entries())
+	assert.Len(t, m.Flush(), 1)
+	assert.Empty(t, m.Flush())
+	assert.Len(t, m.Entries(), 1)
+}
+
+func TestMeterKeysSharingASuffix(t *testing.T) {
+	m := NewMeter(Limits{}, nil)
+	require.NoError(t, m.Request("/api/dns", "first-key-abcd"))
+	require.NoError(t, m.Request("/api/dns", "other-key-abcd"))
+	require.NoError(t, m.Request("/api/dns", "other-key-abcd"))
+
+	entries := m.Entries()
+	require.Len(t, entries, 2)
+	for _, e := range entries {
+		assert.Equal(t, "****abcd", e.Key)
+	}
+	assert.NotEqual(t, entries[0].KeyID, entries[1].KeyID)
+	assert.ElementsMatch(t, []int{1, 2}, []int{entries[0].Requests, entries[1].Requests})
+
+	ledger := Ledger{Path: filepath.Join(t.TempDir(), "usage.json")}
+	require.NoError(t, ledger.Add(m.Flush()))
+	require.NoError(t, ledger.Add([]Entry{{Day: entries[0].Day, Endpoint: "/api/dns", Key: "****abcd", Counts: Counts{Requests: 5}}}))
+	loaded, err := ledger.Load()
+	require.NoError(t, err)
+	assert.Len(t, loaded, 3)
+
+	totals, err := Summarize(loaded, "day", "key", 0)
+	require.NoError(t, err)
+	assert.Len(t, totals, 3)
+}
+
+func TestLedgerAdd(t *testing.T) {
+	ledger := Ledger{Path: filepath.Join(t.TempDir(), "state", "usage.json")}
+	entry := Entry{Day: "2024-05-01", Endpoint: "/api/dns", Key: "****abcd", Counts: Counts{Requests: 2, Records: 10}}
+
+	require.NoError(t, ledger.Add([]Entry{entry}))
+	require.NoError(t, ledger.Add([]Entry{entry, {Day: "2024-05-02", Endpoint: "/api/dns", Key: "****abcd", Counts: Counts{Errors: 1}}}))
+
+	entries, err := ledger.Load()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, Counts{Requests: 4, Records: 20}, entries[0].Counts)
+	assert.Equal(t, 1, entries[1].Errors)
+}
+
+func TestSummarize(t *testing.T) {
+	entries := []Entry{
+		{Day: "2024-04-30", Endpoint: "/api/dns", Key: "****aaaa", Counts: Counts{Requests: 1}},
+		{Day: "2024-05-01", Endpoint: "/api/dns", Key: "****aaaa", Counts: Counts{Requests: 2}},
+		{Day: "2024-05-02", Endpoint: "/api/dns/paging", Key: "****bbbb", Counts: Counts{Requests: 3}},
+	}
+
+	totals, err := Summarize(entries, "month", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{
+		{Period: "2024-04", Counts: Counts{Requests: 1}},
+		{Period: "2024-05", Counts: Counts{Requests: 5}},
+	}, totals)
+
+	totals, err = Summarize(entries, "day", "key", 2)
+	require.NoError(t, err)
+	assert.Equal(t, []Total{
+		{Period: "2024-05-01", Group: "****aaaa", Counts: Counts{Requests: 2}},
+		{Period: "2024-05-02", Group: "****bbbb", Counts: Counts{Requests: 3}},
+	}, totals)
+
+	var csv bytes.Buffer
+	require.NoError(t, WriteTotals(&csv, totals, "key", "csv"))
+	assert.Equal(t, "period,key,requests,pages,records,errors\n2024-05-01,****aaaa,2,0,0,0\n2024-05-02,****bbbb,3,0,0,0\n", csv.String())
+
+	_, err = Summarize(entries, "week", "", 0)
+	assert.Error(t, err)
+}
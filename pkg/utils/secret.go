@@ -0,0 +1,13 @@
+package utils
+
+// RedactSecret masks a secret, keeping only the last few characters so
+// different secrets can still be told apart. An empty secret stays empty.
+func RedactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"":                     {},
+		"2024-05-01":           time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		"2024-05-01T10:00:00Z": time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		"1714564800":           time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"30d":                  time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		"2w":                   time.Date(2024, 5, 17, 12, 0, 0, 0, time.UTC),
+		"36h":                  time.Date(2024, 5, 30, 0, 0, 0, 0, time.UTC),
+	}
+	for value, want := range tests {
+		got, err := ParseTime(value, now)
+		assert.NoError(t, err, value)
+		assert.True(t, want.Equal(got), "%s: want %s, got %s", value, want, got)
+	}
+
+	for _, value := range []string{"yesterday", "-3d", "2024-13-01"} {
+		_, err := ParseTime(value, now)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,249 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Doom-z/RepClient/pkg/fileutil"
+	"github.com/Doom-z/RepClient/pkg/logger"
+)
+
+// SignatureHeader carries the hex encoded HMAC-SHA256 of the request body,
+// prefixed with "sha256=", when a secret is configured.
+const SignatureHeader = "X-Repclient-Signature"
+
+type Config struct {
+	URL    string
+	Secret string
+	// Template renders the request body, the default body is used when empty.
+	Template      string
+	Headers       map[string]string
+	BatchSize     int
+	FlushInterval time.Duration
+	MaxRetries    int
+	Backoff       time.Duration
+	Timeout       time.Duration
+	// DeadLetter is an ndjson file receiving the batches that failed every retry.
+	DeadLetter string
+}
+
+// Payload is the default request body and the data passed to templates.
+type Payload struct {
+	SentAt time.Time `json:"sent_at"`
+	Count  int       `json:"count"`
+	Items  []any     `json:"items"`
+}
+
+type deadLetter struct {
+	Time  time.Time       `json:"time"`
+	URL   string          `json:"url"`
+	Error string          `json:"error"`
+	Body  json.RawMessage `json:"body"`
+}
+
+// Sender POSTs items to a webhook in batches. Items are buffered until
+// BatchSize is reached or FlushInterval elapsed.
+type Sender struct {
+	cfg    Config
+	client *http.Client
+	tmpl   *template.Template
+
+	mu     sync.Mutex
+	buffer []any
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// New creates a sender. When httpClient is nil a client with cfg.Timeout is used.
+func New(cfg Config, httpClient *http.Client) (*Sender, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("webhook url is required")
+	}
+	if cfg.BatchSize < 1 {
+		cfg.BatchSize = 1
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: cfg.Timeout}
+	}
+
+	s := &Sender{
+		cfg:    cfg,
+		client: httpClient,
+		stop:   make(chan struct{}),
+	}
+	if cfg.Template != "" {
+		tmpl, err := template.New("webhook").Funcs(funcs).Parse(cfg.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid webhook template: %w", err)
+		}
+		s.tmpl = tmpl
+	}
+
+	if cfg.FlushInterval > 0 {
+		s.wg.Add(1)
+		go s.flushLoop()
+	}
+	return s, nil
+}
+
+func (s *Sender) flushLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(); err != nil {
+				logger.Warnf("webhook flush error: %v", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Write buffers item and sends the batch once it is full.
+func (s *Sender) Write(item any) error {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, item)
+	if len(s.buffer) < s.cfg.BatchSize {
+		s.mu.Unlock()
+		return nil
+	}
+	batch := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	return s.Send(batch)
+}
+
+// Flush sends the buffered items, if any.
+func (s *Sender) Flush() error {
+	s.mu.Lock()
+	batch := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return s.Send(batch)
+}
+
+// Close stops the flush loop and sends the remaining items.
+func (s *Sender) Close() error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.wg.Wait()
+	return s.Flush()
+}
+
+// Send POSTs items as a single batch right away, bypassing the buffer.
+// A batch that still fails after MaxRetries is written to the dead letter file.
+func (s *Sender) Send(items []any) error {
+	body, err := s.render(items)
+	if err != nil {
+		return err
+	}
+
+	var sendErr error
+	for attempt := 0; attempt <= s.cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			wait := s.cfg.Backoff << (attempt - 1)
+			logger.Debugf("webhook retry %d/%d in %s: %v", attempt, s.cfg.MaxRetries, wait, sendErr)
+			time.Sleep(wait)
+		}
+
+		var retry bool
+		retry, sendErr = s.post(body)
+		if sendErr == nil || !retry {
+			break
+		}
+	}
+	if sendErr == nil {
+		return nil
+	}
+
+	if s.cfg.DeadLetter != "" {
+		letter := deadLetter{Time: time.Now().UTC(), URL: s.cfg.URL, Error: sendErr.Error(), Body: body}
+		if !json.Valid(body) {
+			letter.Body, _ = json.Marshal(string(body))
+		}
+		if err := fileutil.SaveData(letter, s.cfg.DeadLetter, "append"); err != nil {
+			logger.Warnf("failed to write webhook dead letter: %v", err)
+		}
+	}
+	return fmt.Errorf("webhook delivery of %d items failed: %w", len(items), sendErr)
+}
+
+func (s *Sender) render(items []any) ([]byte, error) {
+	payload := Payload{SentAt: time.Now().UTC(), Count: len(items), Items: items}
+	if s.tmpl == nil {
+		return json.Marshal(payload)
+	}
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("webhook template error: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// post sends body once and reports whether a failure is worth retrying.
+func (s *Sender) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("request creation error: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	if s.cfg.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(s.cfg.Secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("request error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body using secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
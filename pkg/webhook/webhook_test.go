@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderBatchesAndSigns(t *testing.T) {
+	var received []Payload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "sha256="+Sign("secret", body), r.Header.Get(SignatureHeader))
+
+		var p Payload
+		assert.NoError(t, json.Unmarshal(body, &p))
+		received = append(received, p)
+	}))
+	defer srv.Close()
+
+	s, err := New(Config{URL: srv.URL, Secret: "secret", BatchSize: 2}, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, s.Write("a"))
+	assert.Empty(t, received)
+	assert.NoError(t, s.Write("b"))
+	assert.NoError(t, s.Write("c"))
+	assert.NoError(t, s.Close())
+
+	assert.Len(t, received, 2)
+	assert.Equal(t, 2, received[0].Count)
+	assert.Equal(t, []any{"c"}, received[1].Items)
+}
+
+func TestSenderTemplate(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+	}))
+	defer srv.Close()
+
+	s, err := New(Config{URL: srv.URL, Template: `{"text":"{{.Count}} records","items":{{json .Items}}}`}, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Send([]any{map[string]any{"ip": "1.1.1.1"}}))
+	assert.Equal(t, `{"text":"1 records","items":[{"ip":"1.1.1.1"}]}`, body)
+}
+
+func TestSenderRetriesAndDeadLetters(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	deadLetter := filepath.Join(t.TempDir(), "dead.ndjson")
+	s, err := New(Config{URL: srv.URL, MaxRetries: 2, Backoff: time.Millisecond, DeadLetter: deadLetter}, nil)
+	assert.NoError(t, err)
+
+	assert.Error(t, s.Send([]any{"a"}))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	content, err := os.ReadFile(deadLetter)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `"error":"unexpected status 503`)
+}
+
+func TestSenderDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	s, err := New(Config{URL: srv.URL, MaxRetries: 3, Backoff: time.Millisecond}, nil)
+	assert.NoError(t, err)
+	assert.Error(t, s.Send([]any{"a"}))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}